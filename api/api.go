@@ -0,0 +1,48 @@
+// Package api issues common FreeSWITCH api commands and parses their output into Go structs,
+// asking for `as json` or xml output where FreeSWITCH supports it.
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2"
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// ErrUnexpectedResponse the output of a command could not be parsed
+var ErrUnexpectedResponse = errors.New("unexpected api response")
+
+// Sender sends a command and waits for its reply, implemented by *esl.Connection and *esl.Client
+type Sender interface {
+	SendCommand(ctx context.Context, cmd command.Command, fn ...esl.EventHandler) (*esl.RawResponse, error)
+}
+
+// SenderFunc adapts a function to Sender, e.g. SenderFunc(pool.Do)
+type SenderFunc func(ctx context.Context, cmd command.Command, fn ...esl.EventHandler) (*esl.RawResponse, error)
+
+// SendCommand call f
+func (f SenderFunc) SendCommand(ctx context.Context, cmd command.Command, fn ...esl.EventHandler) (*esl.RawResponse, error) {
+	return f(ctx, cmd, fn...)
+}
+
+// call send `api name args` and return its body without the trailing newline, -ERR and -USAGE
+// replies are returned as errors wrapping esl.ErrCommandFailed
+func call(ctx context.Context, s Sender, name, args string) (string, error) {
+	resp, err := s.SendCommand(ctx, command.API{Command: name, Arguments: args})
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimRight(string(resp.Body), "\r\n")
+	if strings.HasPrefix(body, "-ERR") || strings.HasPrefix(body, "-USAGE") {
+		return "", fmt.Errorf("%s: %w", body, esl.ErrCommandFailed)
+	}
+	return body, nil
+}
+
+// unexpected error of output not matching what name should print
+func unexpected(name, body string) error {
+	return fmt.Errorf("%s: %q: %w", name, body, ErrUnexpectedResponse)
+}
@@ -0,0 +1,223 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+const statusOutput = `UP 0 years, 1 day, 2 hours, 3 minutes, 4 seconds, 5 milliseconds, 6 microseconds
+FreeSWITCH (Version 1.10.7 -release 64bit) is ready
+1520 session(s) since startup
+3 session(s) - peak 42, last 5min 7
+1 session(s) per Sec out of max 30, peak 12, last 5min 2
+1000 session(s) max
+min idle cpu 0.00/97.53
+Current Stack Size/Max 240K/8192K
+`
+
+const channelsOutput = `{"row_count":1,"rows":[{"uuid":"call-1","direction":"inbound","created":"2022-01-13 10:00:00",` +
+	`"created_epoch":"1642068000","name":"sofia/internal/1000@10.0.0.1","state":"CS_EXECUTE","cid_name":"Alice",` +
+	`"cid_num":"1000","ip_addr":"10.0.0.2","dest":"9999","application":"park","application_data":"",` +
+	`"dialplan":"XML","context":"default","read_codec":"PCMU","read_rate":"8000","write_codec":"PCMU",` +
+	`"write_rate":"8000","secure":"","hostname":"fs1","callstate":"ACTIVE","initial_cid_name":"Alice"}]}
+`
+
+const callsOutput = `{"row_count":1,"rows":[{"uuid":"call-1","direction":"inbound","created_epoch":"1642068000",` +
+	`"name":"sofia/internal/1000@10.0.0.1","callstate":"ACTIVE","cid_num":"1000","call_uuid":"call-1",` +
+	`"b_uuid":"call-2","b_direction":"outbound","b_created_epoch":"1642068003","b_name":"sofia/internal/1001@10.0.0.3",` +
+	`"b_callstate":"ACTIVE","b_cid_num":"1000","call_created_epoch":"1642068005"}]}
+`
+
+const sofiaOutput = `<?xml version="1.0" encoding="ISO-8859-1"?>
+<profiles>
+  <profile>
+    <name>internal</name>
+    <type>profile</type>
+    <data>sip:mod_sofia@10.0.0.1:5060</data>
+    <state>RUNNING (2)</state>
+  </profile>
+  <alias>
+    <name>10.0.0.1</name>
+    <type>alias</type>
+    <data>internal</data>
+    <state>ALIASED</state>
+  </alias>
+  <gateway>
+    <name>external::gw1</name>
+    <type>gateway</type>
+    <data>sip:gw1@10.0.0.9</data>
+    <state>REGED</state>
+  </gateway>
+</profiles>
+`
+
+// newTestClient client of a fake FreeSWITCH answering the commands of this package
+func newTestClient(t *testing.T) *esl.Client {
+	server, err := esltest.NewServer(esltest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	server.HandleAPI("status", func(args string) string { return statusOutput })
+	server.HandleAPI("show", func(args string) string {
+		switch args {
+		case "channels as json":
+			return channelsOutput
+		case "calls as json":
+			return callsOutput
+		}
+		return "-USAGE: show <command>\n"
+	})
+	server.HandleAPI("sofia", func(args string) string { return sofiaOutput })
+	server.HandleAPI("uuid_exists", func(args string) string {
+		if args == "call-1" {
+			return "true"
+		}
+		return "false"
+	})
+	server.HandleAPI("global_getvar", func(args string) string {
+		switch args {
+		case "":
+			return "hostname=fs1\ndomain=10.0.0.1\nsound_prefix=/usr/share/freeswitch/sounds/en/us/callie\n"
+		case "domain":
+			return "10.0.0.1"
+		}
+		return ""
+	})
+
+	client, err := esl.Dial(context.Background(), server.Addr(), esl.WithPassword(esltest.DefaultPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return client
+}
+
+func TestGetStatus(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	status, err := GetStatus(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := &Status{
+		Uptime:                    26*time.Hour + 3*time.Minute + 4*time.Second + 5*time.Millisecond + 6*time.Microsecond,
+		Version:                   "1.10.7 -release 64bit",
+		Ready:                     true,
+		SessionsSinceStartup:      1520,
+		Sessions:                  3,
+		SessionsPeak:              42,
+		SessionsPeak5Min:          7,
+		MaxSessions:               1000,
+		SessionsPerSecond:         1,
+		MaxSessionsPerSecond:      30,
+		SessionsPerSecondPeak:     12,
+		SessionsPerSecondPeak5Min: 2,
+		MinIdleCPU:                0,
+		IdleCPU:                   97.53,
+	}
+	if !reflect.DeepEqual(status, want) {
+		t.Errorf("GetStatus() = %+v, want %+v", status, want)
+	}
+	if _, err := parseStatus("+OK"); !errors.Is(err, ErrUnexpectedResponse) {
+		t.Errorf("parseStatus() error = %v, want %v", err, ErrUnexpectedResponse)
+	}
+}
+
+func TestShow(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	channels, err := ShowChannels(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(channels) != 1 {
+		t.Fatalf("ShowChannels() = %+v", channels)
+	}
+	c := channels[0]
+	if c.UUID != "call-1" || c.CallerIDName != "Alice" || c.Application != "park" || c.ReadRate != 8000 ||
+		c.Created != time.Unix(1642068000, 0) || c.Fields["initial_cid_name"] != "Alice" {
+		t.Errorf("ShowChannels() = %+v", c)
+	}
+
+	calls, err := ShowCalls(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 1 || calls[0].B == nil {
+		t.Fatalf("ShowCalls() = %+v", calls)
+	}
+	call := calls[0]
+	if call.A.UUID != "call-1" || call.B.UUID != "call-2" || call.B.Direction != "outbound" ||
+		call.Created != time.Unix(1642068005, 0) {
+		t.Errorf("ShowCalls() = %+v, B = %+v", call, call.B)
+	}
+	if _, ok := call.A.Fields["b_uuid"]; ok {
+		t.Errorf("a leg fields contain b leg columns: %v", call.A.Fields)
+	}
+}
+
+func TestSofiaStatus(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	entries, err := SofiaStatus(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []SofiaEntry{
+		{Name: "internal", Type: "profile", Data: "sip:mod_sofia@10.0.0.1:5060", State: "RUNNING (2)"},
+		{Name: "10.0.0.1", Type: "alias", Data: "internal", State: "ALIASED"},
+		{Name: "external::gw1", Type: "gateway", Data: "sip:gw1@10.0.0.9", State: "REGED"},
+	}
+	if !reflect.DeepEqual(entries, want) {
+		t.Errorf("SofiaStatus() = %+v, want %+v", entries, want)
+	}
+}
+
+func TestVars(t *testing.T) {
+	client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	for uuid, want := range map[string]bool{"call-1": true, "call-9": false} {
+		if ok, err := UUIDExists(ctx, client, uuid); err != nil || ok != want {
+			t.Errorf("UUIDExists(%s) = %v, %v, want %v", uuid, ok, err, want)
+		}
+	}
+	if value, err := GlobalGetVar(ctx, client, "domain"); err != nil || value != "10.0.0.1" {
+		t.Errorf("GlobalGetVar(domain) = %q, %v", value, err)
+	}
+	if value, err := GlobalGetVar(ctx, client, "missing"); err != nil || value != "" {
+		t.Errorf("GlobalGetVar(missing) = %q, %v", value, err)
+	}
+	vars, err := GlobalVars(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(vars) != 3 || vars["hostname"] != "fs1" {
+		t.Errorf("GlobalVars() = %v", vars)
+	}
+
+	// a Pool sends through SenderFunc
+	pool := esl.NewPool(client.DialConn, esl.PoolOptions{})
+	defer pool.Close()
+	if _, err := GlobalGetVar(ctx, SenderFunc(pool.Do), "x"); err != nil {
+		t.Fatal(err)
+	}
+	// the fake server answers -ERR to unknown commands
+	if _, err := call(ctx, client, "conference", "list"); !errors.Is(err, esl.ErrCommandFailed) {
+		t.Errorf("call() error = %v, want %v", err, esl.ErrCommandFailed)
+	}
+}
@@ -0,0 +1,154 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Channel row of `show channels`, a leg or b leg of `show calls`
+type Channel struct {
+	UUID      string
+	Direction string
+	Created   time.Time
+	Name      string
+	State     string
+	CallState string
+
+	CallerIDName   string
+	CallerIDNumber string
+	IPAddr         string
+	Destination    string
+	Application    string
+	// ApplicationData arguments of Application
+	ApplicationData string
+	Dialplan        string
+	Context         string
+
+	ReadCodec  string
+	ReadRate   int
+	WriteCodec string
+	WriteRate  int
+	Secure     string
+
+	CalleeName      string
+	CalleeNumber    string
+	CalleeDirection string
+	CallUUID        string
+	Hostname        string
+	PresenceID      string
+	AccountCode     string
+
+	// Fields every column of the row, e.g. initial_cid_name
+	Fields map[string]string
+}
+
+// Call row of `show calls`, a bridged pair of channels
+type Call struct {
+	A Channel
+	// B nil when the a leg is not bridged
+	B       *Channel
+	Created time.Time
+}
+
+// showResult `show ... as json` output, rows is missing when row_count is zero
+type showResult struct {
+	RowCount int                 `json:"row_count"`
+	Rows     []map[string]string `json:"rows"`
+}
+
+// ShowChannels issue `show channels as json`
+func ShowChannels(ctx context.Context, s Sender) ([]Channel, error) {
+	rows, err := show(ctx, s, "channels")
+	if err != nil {
+		return nil, err
+	}
+	channels := make([]Channel, 0, len(rows))
+	for _, row := range rows {
+		channels = append(channels, newChannel(row, ""))
+	}
+	return channels, nil
+}
+
+// ShowCalls issue `show calls as json`
+func ShowCalls(ctx context.Context, s Sender) ([]Call, error) {
+	rows, err := show(ctx, s, "calls")
+	if err != nil {
+		return nil, err
+	}
+	calls := make([]Call, 0, len(rows))
+	for _, row := range rows {
+		call := Call{A: newChannel(row, ""), Created: epoch(row["call_created_epoch"])}
+		if row["b_uuid"] != "" {
+			b := newChannel(row, "b_")
+			call.B = &b
+		}
+		calls = append(calls, call)
+	}
+	return calls, nil
+}
+
+func show(ctx context.Context, s Sender, what string) ([]map[string]string, error) {
+	body, err := call(ctx, s, "show", what+" as json")
+	if err != nil {
+		return nil, err
+	}
+	var result showResult
+	if err := json.Unmarshal([]byte(body), &result); err != nil {
+		return nil, unexpected("show "+what, body)
+	}
+	return result.Rows, nil
+}
+
+// newChannel channel from the columns of row starting with prefix
+func newChannel(row map[string]string, prefix string) Channel {
+	fields := make(map[string]string)
+	for k, v := range row {
+		if prefix == "" && strings.HasPrefix(k, "b_") {
+			continue
+		}
+		if strings.HasPrefix(k, prefix) {
+			fields[strings.TrimPrefix(k, prefix)] = v
+		}
+	}
+	return Channel{
+		UUID:            fields["uuid"],
+		Direction:       fields["direction"],
+		Created:         epoch(fields["created_epoch"]),
+		Name:            fields["name"],
+		State:           fields["state"],
+		CallState:       fields["callstate"],
+		CallerIDName:    fields["cid_name"],
+		CallerIDNumber:  fields["cid_num"],
+		IPAddr:          fields["ip_addr"],
+		Destination:     fields["dest"],
+		Application:     fields["application"],
+		ApplicationData: fields["application_data"],
+		Dialplan:        fields["dialplan"],
+		Context:         fields["context"],
+		ReadCodec:       fields["read_codec"],
+		ReadRate:        atoi(fields["read_rate"]),
+		WriteCodec:      fields["write_codec"],
+		WriteRate:       atoi(fields["write_rate"]),
+		Secure:          fields["secure"],
+		CalleeName:      fields["callee_name"],
+		CalleeNumber:    fields["callee_num"],
+		CalleeDirection: fields["callee_direction"],
+		CallUUID:        fields["call_uuid"],
+		Hostname:        fields["hostname"],
+		PresenceID:      fields["presence_id"],
+		AccountCode:     fields["accountcode"],
+		Fields:          fields,
+	}
+}
+
+// epoch time of a unix seconds column, zero when empty
+func epoch(s string) time.Time {
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil || n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(n, 0)
+}
@@ -0,0 +1,70 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// SofiaEntry profile, alias or gateway listed by `sofia xmlstatus`
+type SofiaEntry struct {
+	Name string
+	// Type "profile", "alias" or "gateway"
+	Type string
+	// Data SIP URI of a profile or gateway, profile name of an alias
+	Data string
+	// State e.g. "RUNNING (0)" for a profile with no call, "REGED" for a gateway
+	State string
+}
+
+type sofiaStatus struct {
+	Entries []struct {
+		Name  string `xml:"name"`
+		Type  string `xml:"type"`
+		Data  string `xml:"data"`
+		State string `xml:"state"`
+	} `xml:",any"`
+}
+
+// SofiaStatus issue `sofia xmlstatus`
+func SofiaStatus(ctx context.Context, s Sender) ([]SofiaEntry, error) {
+	body, err := call(ctx, s, "sofia", "xmlstatus")
+	if err != nil {
+		return nil, err
+	}
+	var status sofiaStatus
+	decoder := xml.NewDecoder(strings.NewReader(body))
+	decoder.CharsetReader = charsetReader
+	if err := decoder.Decode(&status); err != nil {
+		return nil, unexpected("sofia xmlstatus", body)
+	}
+	entries := make([]SofiaEntry, 0, len(status.Entries))
+	for _, e := range status.Entries {
+		entries = append(entries, SofiaEntry{
+			Name:  strings.TrimSpace(e.Name),
+			Type:  strings.TrimSpace(e.Type),
+			Data:  strings.TrimSpace(e.Data),
+			State: strings.TrimSpace(e.State),
+		})
+	}
+	return entries, nil
+}
+
+// charsetReader FreeSWITCH declares its xml as ISO-8859-1
+func charsetReader(charset string, input io.Reader) (io.Reader, error) {
+	var latin1 bytes.Buffer
+	if _, err := latin1.ReadFrom(input); err != nil {
+		return nil, err
+	}
+	if utf8.Valid(latin1.Bytes()) {
+		return &latin1, nil
+	}
+	var out bytes.Buffer
+	for _, b := range latin1.Bytes() {
+		out.WriteRune(rune(b))
+	}
+	return &out, nil
+}
@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Status output of `status`
+type Status struct {
+	Uptime time.Duration
+	// Version e.g. "1.10.7 -release 64bit"
+	Version string
+	// Ready FreeSWITCH is ready to accept calls, false while it starts up or shuts down
+	Ready bool
+
+	SessionsSinceStartup int
+	Sessions             int
+	SessionsPeak         int
+	SessionsPeak5Min     int
+	MaxSessions          int
+
+	SessionsPerSecond         int
+	MaxSessionsPerSecond      int
+	SessionsPerSecondPeak     int
+	SessionsPerSecondPeak5Min int
+
+	// MinIdleCPU configured min-idle-cpu percentage
+	MinIdleCPU float64
+	// IdleCPU current idle cpu percentage
+	IdleCPU float64
+}
+
+var (
+	uptimeRe     = regexp.MustCompile(`(\d+) (year|day|hour|minute|second|millisecond|microsecond)s?`)
+	versionRe    = regexp.MustCompile(`^FreeSWITCH \(Version (.*)\) is (.*)$`)
+	startupRe    = regexp.MustCompile(`^(\d+) session\(s\) since startup$`)
+	sessionsRe   = regexp.MustCompile(`^(\d+) session\(s\) - peak (\d+), last 5min (\d+)$`)
+	perSecondRe  = regexp.MustCompile(`^(\d+) session\(s\) per Sec out of max (\d+), peak (\d+), last 5min (\d+)$`)
+	maxSessionRe = regexp.MustCompile(`^(\d+) session\(s\) max$`)
+	idleCPURe    = regexp.MustCompile(`^min idle cpu ([\d.]+)/([\d.]+)$`)
+)
+
+var uptimeUnits = map[string]time.Duration{
+	"year":        365 * 24 * time.Hour,
+	"day":         24 * time.Hour,
+	"hour":        time.Hour,
+	"minute":      time.Minute,
+	"second":      time.Second,
+	"millisecond": time.Millisecond,
+	"microsecond": time.Microsecond,
+}
+
+// GetStatus issue `status`
+func GetStatus(ctx context.Context, s Sender) (*Status, error) {
+	body, err := call(ctx, s, "status", "")
+	if err != nil {
+		return nil, err
+	}
+	return parseStatus(body)
+}
+
+func parseStatus(body string) (*Status, error) {
+	lines := strings.Split(body, "\n")
+	if !strings.HasPrefix(lines[0], "UP ") {
+		return nil, unexpected("status", body)
+	}
+	status := &Status{}
+	for _, m := range uptimeRe.FindAllStringSubmatch(lines[0], -1) {
+		n, _ := strconv.ParseInt(m[1], 10, 64)
+		status.Uptime += time.Duration(n) * uptimeUnits[m[2]]
+	}
+	for _, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if m := versionRe.FindStringSubmatch(line); m != nil {
+			status.Version = m[1]
+			status.Ready = m[2] == "ready"
+		} else if m := startupRe.FindStringSubmatch(line); m != nil {
+			status.SessionsSinceStartup = atoi(m[1])
+		} else if m := sessionsRe.FindStringSubmatch(line); m != nil {
+			status.Sessions, status.SessionsPeak, status.SessionsPeak5Min = atoi(m[1]), atoi(m[2]), atoi(m[3])
+		} else if m := perSecondRe.FindStringSubmatch(line); m != nil {
+			status.SessionsPerSecond, status.MaxSessionsPerSecond = atoi(m[1]), atoi(m[2])
+			status.SessionsPerSecondPeak, status.SessionsPerSecondPeak5Min = atoi(m[3]), atoi(m[4])
+		} else if m := maxSessionRe.FindStringSubmatch(line); m != nil {
+			status.MaxSessions = atoi(m[1])
+		} else if m := idleCPURe.FindStringSubmatch(line); m != nil {
+			status.MinIdleCPU, _ = strconv.ParseFloat(m[1], 64)
+			status.IdleCPU, _ = strconv.ParseFloat(m[2], 64)
+		}
+	}
+	return status, nil
+}
+
+// atoi digits matched by a regexp
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,47 @@
+package api
+
+import (
+	"context"
+	"strings"
+)
+
+// UUIDExists issue `uuid_exists uuid`
+func UUIDExists(ctx context.Context, s Sender, uuid string) (bool, error) {
+	body, err := call(ctx, s, "uuid_exists", uuid)
+	if err != nil {
+		return false, err
+	}
+	switch body {
+	case "true":
+		return true, nil
+	case "false":
+		return false, nil
+	}
+	return false, unexpected("uuid_exists", body)
+}
+
+// GlobalGetVar issue `global_getvar name`, empty when the variable is not set
+func GlobalGetVar(ctx context.Context, s Sender, name string) (string, error) {
+	return call(ctx, s, "global_getvar", name)
+}
+
+// GlobalVars issue `global_getvar` without a name, listing every global variable
+func GlobalVars(ctx context.Context, s Sender) (map[string]string, error) {
+	body, err := call(ctx, s, "global_getvar", "")
+	if err != nil {
+		return nil, err
+	}
+	vars := make(map[string]string)
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" {
+			continue
+		}
+		kv := strings.SplitN(line, "=", 2)
+		if len(kv) != 2 {
+			return nil, unexpected("global_getvar", body)
+		}
+		vars[kv[0]] = kv[1]
+	}
+	return vars, nil
+}
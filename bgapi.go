@@ -0,0 +1,271 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+var (
+	// DefaultJobTTL how long a background job waits for its BACKGROUND_JOB before it is expired,
+	// changed per connection by SetJobTTL
+	DefaultJobTTL = 10 * time.Minute
+	// JobSweepInterval how often expired background jobs are removed
+	JobSweepInterval = time.Second
+)
+
+// JobResult result of a background job, parsed from its BACKGROUND_JOB event
+type JobResult struct {
+	JobUUID string
+	Command string
+	Args    string
+	// OK false when the body starts with -ERR or -USAGE
+	OK bool
+	// Reply body without the +OK/-ERR prefix and trailing new line
+	Reply string
+	Event *Event
+}
+
+// Err return the job failure as error, nil when OK
+func (r *JobResult) Err() error {
+	if r.OK {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", r.Reply, ErrJobFailed)
+}
+
+func newJobResult(e *Event) *JobResult {
+	body := strings.TrimRight(string(e.Body), "\r\n")
+	result := &JobResult{
+		JobUUID: e.GetHeader("Job-UUID"),
+		Command: e.GetHeader("Job-Command"),
+		Args:    e.GetHeader("Job-Command-Arg"),
+		OK:      true,
+		Reply:   body,
+		Event:   e,
+	}
+	switch {
+	case strings.HasPrefix(body, "+OK"):
+		result.Reply = strings.TrimSpace(body[len("+OK"):])
+	case strings.HasPrefix(body, "-ERR"):
+		result.OK = false
+		result.Reply = strings.TrimSpace(body[len("-ERR"):])
+	case strings.HasPrefix(body, "-USAGE"):
+		result.OK = false
+		result.Reply = strings.TrimSpace(strings.TrimPrefix(body[len("-USAGE"):], ":"))
+	}
+	return result
+}
+
+// Job background job started by StartBgAPI
+type Job struct {
+	UUID string
+
+	done   chan struct{}
+	result *JobResult
+	err    error
+}
+
+func newJob(uuid string) *Job {
+	return &Job{
+		UUID: uuid,
+		done: make(chan struct{}),
+	}
+}
+
+// Done closed when the job completed, expired or its connection closed
+func (j *Job) Done() <-chan struct{} {
+	return j.done
+}
+
+// Result job result once Done is closed. The error is ErrJobExpired when no BACKGROUND_JOB
+// arrived within the job ttl, ErrConnClosed when the connection closed first.
+func (j *Job) Result() (*JobResult, error) {
+	select {
+	case <-j.done:
+		return j.result, j.err
+	default:
+		return nil, ErrJobPending
+	}
+}
+
+// Wait wait for the job result or ctx done
+func (j *Job) Wait(ctx context.Context) (*JobResult, error) {
+	select {
+	case <-j.done:
+		return j.result, j.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (j *Job) complete(e *Event) {
+	j.result = newJobResult(e)
+	close(j.done)
+}
+
+func (j *Job) fail(err error) {
+	j.err = err
+	close(j.done)
+}
+
+// StartBgAPI run `bgapi cmd args` and return once FreeSWITCH accepted the job, ctx only bounds the send
+func (c *Connection) StartBgAPI(ctx context.Context, cmd, args string) (*Job, error) {
+	job := newJob(newUUID())
+	c.addJob(job.UUID, &bgJob{cb: job.complete, fail: job.fail})
+
+	response, err := c.SendCommand(ctx, command.API{
+		Command:    cmd,
+		Arguments:  args,
+		Background: true,
+		JobUUID:    job.UUID,
+	})
+	if err != nil {
+		c.removeJob(job.UUID)
+		return nil, err
+	}
+	if !response.IsOk() {
+		c.removeJob(job.UUID)
+		return nil, fmt.Errorf("%s: %w", response.GetReply(), ErrUnsuccessfulReply)
+	}
+	job.UUID = c.settleJob(job.UUID, response)
+	return job, nil
+}
+
+// BgAPI run `bgapi cmd args` and wait until its BACKGROUND_JOB arrives, ctx expires or the connection closes.
+// A job failing with -ERR is returned as a JobResult, see JobResult.Err.
+func (c *Connection) BgAPI(ctx context.Context, cmd, args string) (*JobResult, error) {
+	job, err := c.StartBgAPI(ctx, cmd, args)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := job.Wait(ctx)
+	if err != nil && ctx.Err() != nil {
+		c.removeJob(job.UUID)
+	}
+	return result, err
+}
+
+// SetJobTTL set how long background jobs wait for their BACKGROUND_JOB, 0 or less never expires
+func (c *Connection) SetJobTTL(ttl time.Duration) {
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	c.filter.bgapi.ttl = ttl
+}
+
+// JobStats background job counters
+type JobStats struct {
+	// Pending jobs waiting for their BACKGROUND_JOB
+	Pending int
+	// Expired jobs removed by the ttl sweeper
+	Expired uint64
+}
+
+// JobStats return background job counters
+func (c *Connection) JobStats() JobStats {
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	return JobStats{
+		Pending: len(c.filter.bgapi.cb),
+		Expired: c.filter.bgapi.expired,
+	}
+}
+
+func (c *Connection) addJob(jobid string, job *bgJob) {
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	if c.filter.bgapi.ttl > 0 {
+		job.expires = time.Now().Add(c.filter.bgapi.ttl)
+	}
+	c.filter.bgapi.cb[jobid] = job
+}
+
+func (c *Connection) removeJob(jobid string) {
+	if len(jobid) == 0 {
+		return
+	}
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	delete(c.filter.bgapi.cb, jobid)
+}
+
+// takeJob remove and return the job of jobid, nil when unknown
+func (c *Connection) takeJob(jobid string) *bgJob {
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	job, ok := c.filter.bgapi.cb[jobid]
+	if !ok {
+		return nil
+	}
+	delete(c.filter.bgapi.cb, jobid)
+	return job
+}
+
+// settleJob drop the callback of a rejected job, or move it when FreeSWITCH chose another Job-UUID.
+// Returns the Job-UUID the job is registered with.
+func (c *Connection) settleJob(jobid string, response *RawResponse) string {
+	if len(jobid) == 0 {
+		return jobid
+	}
+	if !response.IsOk() {
+		c.removeJob(jobid)
+		return jobid
+	}
+	replied := response.Headers.Get("Job-Uuid")
+	if len(replied) == 0 || replied == jobid {
+		return jobid
+	}
+	c.filter.bgapi.Lock()
+	defer c.filter.bgapi.Unlock()
+
+	if job, ok := c.filter.bgapi.cb[jobid]; ok {
+		delete(c.filter.bgapi.cb, jobid)
+		c.filter.bgapi.cb[replied] = job
+	}
+	return replied
+}
+
+// expireJobs remove jobs past their ttl
+func (c *Connection) expireJobs(now time.Time) {
+	expired := make(map[string]*bgJob)
+	c.filter.bgapi.Lock()
+	for jobid, job := range c.filter.bgapi.cb {
+		if !job.expires.IsZero() && now.After(job.expires) {
+			expired[jobid] = job
+			delete(c.filter.bgapi.cb, jobid)
+		}
+	}
+	c.filter.bgapi.expired += uint64(len(expired))
+	c.filter.bgapi.Unlock()
+
+	for jobid, job := range expired {
+		if job.fail != nil {
+			job.fail(ErrJobExpired)
+			continue
+		}
+		c.logger().Warnf("background job %s expired without BACKGROUND_JOB\n", jobid)
+	}
+}
+
+// failJobs remove all jobs, they will never complete
+func (c *Connection) failJobs(err error) {
+	c.filter.bgapi.Lock()
+	jobs := c.filter.bgapi.cb
+	c.filter.bgapi.cb = make(map[string]*bgJob)
+	c.filter.bgapi.Unlock()
+
+	for _, job := range jobs {
+		if job.fail != nil {
+			job.fail(err)
+		}
+	}
+}
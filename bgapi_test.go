@@ -0,0 +1,194 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/command/call"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestConnection_BgAPI(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleAPI("originate", func(args string) string {
+		if args == "user/busy &park()" {
+			return "-ERR USER_BUSY\n"
+		}
+		return "+OK 9f0c2a4e-8d3b-4f7a-b8a1-3c6e1d2f5a70\n"
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	tests := []struct {
+		name      string
+		cmd, args string
+		ok        bool
+		reply     string
+	}{
+		{"ok", "originate", "user/1000 &park()", true, "9f0c2a4e-8d3b-4f7a-b8a1-3c6e1d2f5a70"},
+		{"err", "originate", "user/busy &park()", false, "USER_BUSY"},
+		{"no prefix", "status", "", true, "UP 0 years, 0 days, 0 hours, 12 minutes\nFreeSWITCH (Version 1.10.7 64bit) is ready"},
+		{"not found", "no_such_api", "", false, "no_such_api Command not found!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+			defer cancel()
+			result, err := client.BgAPI(ctx, tt.cmd, tt.args)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.OK != tt.ok || result.Reply != tt.reply {
+				t.Errorf("BgAPI() = %v %q, want %v %q", result.OK, result.Reply, tt.ok, tt.reply)
+			}
+			if result.Command != tt.cmd || result.Args != tt.args {
+				t.Errorf("BgAPI() command = %q %q", result.Command, result.Args)
+			}
+			if (result.Err() == nil) != tt.ok || (!tt.ok && !errors.Is(result.Err(), ErrJobFailed)) {
+				t.Errorf("Err() = %v", result.Err())
+			}
+		})
+	}
+	if n := client.JobStats().Pending; n != 0 {
+		t.Errorf("%d jobs left", n)
+	}
+}
+
+func TestConnection_BgAPICancel(t *testing.T) {
+	server := newTestServer(t)
+	// accept jobs but never send BACKGROUND_JOB
+	server.Handle("bgapi", func(c *esltest.Conn, cmd *esltest.Command) bool {
+		c.Reply("+OK Job-UUID: "+cmd.Headers.Get("Job-UUID"), "Job-UUID", cmd.Headers.Get("Job-UUID"))
+		return true
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := client.BgAPI(ctx, "status", "")
+	if err != context.DeadlineExceeded {
+		t.Errorf("BgAPI() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if n := client.JobStats().Pending; n != 0 {
+		t.Errorf("%d jobs left after cancel", n)
+	}
+
+	errs := make(chan error, 1)
+	go func() {
+		_, err := client.BgAPI(context.Background(), "status", "")
+		errs <- err
+	}()
+	for client.JobStats().Pending == 0 {
+		select {
+		case err := <-errs:
+			t.Fatalf("BgAPI() returned before close: %v", err)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	client.Close()
+	select {
+	case err := <-errs:
+		if err != ErrConnClosed {
+			t.Errorf("BgAPI() error = %v, want %v", err, ErrConnClosed)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("BgAPI() not woken up by close")
+	}
+	if n := client.JobStats().Pending; n != 0 {
+		t.Errorf("%d jobs left after close", n)
+	}
+}
+
+func TestConnection_JobExpired(t *testing.T) {
+	interval := JobSweepInterval
+	JobSweepInterval = 10 * time.Millisecond
+	defer func() { JobSweepInterval = interval }()
+
+	server := newTestServer(t)
+	// accept jobs but never send BACKGROUND_JOB
+	server.Handle("bgapi", func(c *esltest.Conn, cmd *esltest.Command) bool {
+		c.Reply("+OK Job-UUID: "+cmd.Headers.Get("Job-UUID"), "Job-UUID", cmd.Headers.Get("Job-UUID"))
+		return true
+	})
+	client := newTestClient(t, server, "plain", 0)
+	client.SetJobTTL(50 * time.Millisecond)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	job, err := client.StartBgAPI(ctx, "status", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := job.Result(); err != ErrJobPending {
+		t.Errorf("Result() error = %v, want %v", err, ErrJobPending)
+	}
+	_, err = client.SendCommand(ctx, command.API{Command: "status", Background: true}, func(e *Event) {
+		t.Error("expired callback called")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats := client.JobStats(); stats.Pending != 2 {
+		t.Errorf("JobStats() = %+v, want 2 pending", stats)
+	}
+
+	if _, err := job.Wait(ctx); err != ErrJobExpired {
+		t.Errorf("Wait() error = %v, want %v", err, ErrJobExpired)
+	}
+	for client.JobStats().Pending > 0 && ctx.Err() == nil {
+		time.Sleep(time.Millisecond)
+	}
+	if stats := client.JobStats(); stats.Pending != 0 || stats.Expired != 2 {
+		t.Errorf("JobStats() = %+v, want 0 pending 2 expired", stats)
+	}
+}
+
+func TestConnection_Originate(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleAPI("originate", func(args string) string {
+		if strings.HasPrefix(args, "{origination_uuid=") {
+			return "+OK " + args[len("{origination_uuid="):strings.Index(args, "}")] + "\n"
+		}
+		return "-ERR NO_ROUTE_DESTINATION\n"
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	uuid, err := client.Originate(ctx, call.Originate{
+		UUID:      "f81d4fae-7dec-41d0-a765-00a0c91e6bf6",
+		Endpoints: [][]call.Endpoint{{{DialString: "user/1000"}}},
+		App:       "park",
+	})
+	if err != nil || uuid != "f81d4fae-7dec-41d0-a765-00a0c91e6bf6" {
+		t.Errorf("Originate() = %q, %v", uuid, err)
+	}
+	uuid, err = client.Originate(ctx, call.Originate{
+		Endpoints: [][]call.Endpoint{{{DialString: "user/1000"}}},
+		App:       "park",
+	})
+	if err != nil || len(uuid) != 36 {
+		t.Errorf("Originate() with generated uuid = %q, %v", uuid, err)
+	}
+
+	server.HandleAPI("originate", func(args string) string {
+		return "-ERR NO_ROUTE_DESTINATION\n"
+	})
+	_, err = client.Originate(ctx, call.Originate{
+		Endpoints: [][]call.Endpoint{{{DialString: "user/404"}}},
+		Extension: "9999",
+	})
+	if !errors.Is(err, ErrJobFailed) {
+		t.Errorf("Originate() error = %v, want %v", err, ErrJobFailed)
+	}
+	_, err = client.Originate(ctx, call.Originate{Endpoints: [][]call.Endpoint{{{DialString: "user/1000"}}}})
+	if !errors.Is(err, call.ErrNoTarget) {
+		t.Errorf("Originate() without target error = %v, want %v", err, call.ErrNoTarget)
+	}
+	if _, err := client.SendCommand(ctx, call.Originate{}); !errors.Is(err, call.ErrNoTarget) {
+		t.Errorf("SendCommand() error = %v, want %v", err, call.ErrNoTarget)
+	}
+}
@@ -0,0 +1,308 @@
+package esl
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// channel events maintained by ChannelTracker
+const (
+	EventChannelCreate         = "CHANNEL_CREATE"
+	EventChannelAnswer         = "CHANNEL_ANSWER"
+	EventChannelBridge         = "CHANNEL_BRIDGE"
+	EventChannelUnbridge       = "CHANNEL_UNBRIDGE"
+	EventChannelHangup         = "CHANNEL_HANGUP"
+	EventChannelHangupComplete = "CHANNEL_HANGUP_COMPLETE"
+	EventChannelCallState      = "CHANNEL_CALLSTATE"
+)
+
+// ChannelEvents events ChannelTracker subscribes
+var ChannelEvents = []string{
+	EventChannelCreate,
+	EventChannelAnswer,
+	EventChannelBridge,
+	EventChannelUnbridge,
+	EventChannelHangup,
+	EventChannelHangupComplete,
+	EventChannelCallState,
+}
+
+// ChannelState Channel-State header, e.g. CS_EXECUTE
+type ChannelState string
+
+// channel states
+const (
+	ChannelStateNew           ChannelState = "CS_NEW"
+	ChannelStateInit          ChannelState = "CS_INIT"
+	ChannelStateRouting       ChannelState = "CS_ROUTING"
+	ChannelStateSoftExecute   ChannelState = "CS_SOFT_EXECUTE"
+	ChannelStateExecute       ChannelState = "CS_EXECUTE"
+	ChannelStateExchangeMedia ChannelState = "CS_EXCHANGE_MEDIA"
+	ChannelStatePark          ChannelState = "CS_PARK"
+	ChannelStateConsumeMedia  ChannelState = "CS_CONSUME_MEDIA"
+	ChannelStateHibernate     ChannelState = "CS_HIBERNATE"
+	ChannelStateReset         ChannelState = "CS_RESET"
+	ChannelStateHangup        ChannelState = "CS_HANGUP"
+	ChannelStateReporting     ChannelState = "CS_REPORTING"
+	ChannelStateDestroy       ChannelState = "CS_DESTROY"
+)
+
+// CallState Channel-Call-State header, e.g. ACTIVE
+type CallState string
+
+// call states
+const (
+	CallStateDown     CallState = "DOWN"
+	CallStateDialing  CallState = "DIALING"
+	CallStateRinging  CallState = "RINGING"
+	CallStateEarly    CallState = "EARLY"
+	CallStateActive   CallState = "ACTIVE"
+	CallStateHeld     CallState = "HELD"
+	CallStateRingWait CallState = "RING_WAIT"
+	CallStateHangup   CallState = "HANGUP"
+	CallStateUnheld   CallState = "UNHELD"
+)
+
+// AnswerState Answer-State header, e.g. answered
+type AnswerState string
+
+// answer states
+const (
+	AnswerStateRinging  AnswerState = "ringing"
+	AnswerStateEarly    AnswerState = "early"
+	AnswerStateAnswered AnswerState = "answered"
+	AnswerStateHangup   AnswerState = "hangup"
+)
+
+// Channel channel state built from channel events
+type Channel struct {
+	UUID        string
+	Direction   string
+	State       ChannelState
+	CallState   CallState
+	AnswerState AnswerState
+
+	CallerIDName      string
+	CallerIDNumber    string
+	CalleeIDName      string
+	CalleeIDNumber    string
+	DestinationNumber string
+
+	// BridgedUUID uuid of the bridged peer, empty when not bridged
+	BridgedUUID string
+	HangupCause string
+
+	CreatedAt  time.Time
+	AnsweredAt time.Time
+	BridgedAt  time.Time
+	HungupAt   time.Time
+
+	// Variables channel variables without the variable_ prefix
+	Variables map[string]string
+}
+
+// Answered has the channel been answered
+func (ch Channel) Answered() bool {
+	return !ch.AnsweredAt.IsZero()
+}
+
+// Bridged is the channel bridged to a peer
+func (ch Channel) Bridged() bool {
+	return len(ch.BridgedUUID) > 0
+}
+
+// GetVariable get channel variable
+func (ch Channel) GetVariable(name string) string {
+	return ch.Variables[name]
+}
+
+func (ch *Channel) clone() Channel {
+	c := *ch
+	c.Variables = make(map[string]string, len(ch.Variables))
+	for k, v := range ch.Variables {
+		c.Variables[k] = v
+	}
+	return c
+}
+
+func (ch *Channel) update(e *Event) {
+	setString := func(field *string, header string) {
+		if e.HasHeader(header) {
+			*field = e.GetHeader(header)
+		}
+	}
+	setTime := func(field *time.Time, header string) {
+		if t := eventTime(e.GetHeader(header)); !t.IsZero() {
+			*field = t
+		}
+	}
+
+	setString(&ch.Direction, "Call-Direction")
+	if e.HasHeader("Channel-State") {
+		ch.State = ChannelState(e.GetHeader("Channel-State"))
+	}
+	if e.HasHeader("Channel-Call-State") {
+		ch.CallState = CallState(e.GetHeader("Channel-Call-State"))
+	}
+	if e.HasHeader("Answer-State") {
+		ch.AnswerState = AnswerState(e.GetHeader("Answer-State"))
+	}
+	setString(&ch.CallerIDName, "Caller-Caller-ID-Name")
+	setString(&ch.CallerIDNumber, "Caller-Caller-ID-Number")
+	setString(&ch.CalleeIDName, "Caller-Callee-ID-Name")
+	setString(&ch.CalleeIDNumber, "Caller-Callee-ID-Number")
+	setString(&ch.DestinationNumber, "Caller-Destination-Number")
+	setString(&ch.HangupCause, "Hangup-Cause")
+
+	setTime(&ch.CreatedAt, "Caller-Channel-Created-Time")
+	setTime(&ch.AnsweredAt, "Caller-Channel-Answered-Time")
+	setTime(&ch.BridgedAt, "Caller-Channel-Bridged-Time")
+	setTime(&ch.HungupAt, "Caller-Channel-Hangup-Time")
+
+	for key := range e.Headers {
+		if strings.HasPrefix(key, "Variable_") {
+			ch.Variables[key[len("Variable_"):]] = e.GetHeader(key)
+		}
+	}
+
+	switch e.GetName() {
+	case EventChannelBridge:
+		peer := e.GetHeader("Other-Leg-Unique-ID")
+		if len(peer) == 0 {
+			// the a leg reports the b leg and vice versa
+			peer = e.GetHeader("Bridge-B-Unique-ID")
+			if peer == ch.UUID {
+				peer = e.GetHeader("Bridge-A-Unique-ID")
+			}
+		}
+		ch.BridgedUUID = peer
+		if ch.BridgedAt.IsZero() {
+			ch.BridgedAt = eventTime(e.GetHeader("Event-Date-Timestamp"))
+		}
+	case EventChannelUnbridge:
+		ch.BridgedUUID = ""
+	case EventChannelHangup, EventChannelHangupComplete:
+		ch.BridgedUUID = ""
+		if ch.HungupAt.IsZero() {
+			ch.HungupAt = eventTime(e.GetHeader("Event-Date-Timestamp"))
+		}
+	}
+}
+
+// eventTime parse FreeSWITCH microsecond timestamps, "0" means not set
+func eventTime(us string) time.Time {
+	v, err := strconv.ParseInt(us, 10, 64)
+	if err != nil || v <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(v/1e6, (v%1e6)*1e3)
+}
+
+// ChannelTracker maintains Channel state from the event stream, a channel is removed after CHANNEL_HANGUP_COMPLETE
+type ChannelTracker struct {
+	sync.RWMutex
+	channels map[string]*Channel
+	onChange func(ch Channel, e *Event)
+}
+
+// NewChannelTracker create channel tracker, feed it with HandleEvent
+func NewChannelTracker() *ChannelTracker {
+	return &ChannelTracker{
+		channels: make(map[string]*Channel),
+	}
+}
+
+// OnChange set callback called with a snapshot after every update, including the final CHANNEL_HANGUP_COMPLETE one
+func (t *ChannelTracker) OnChange(fn func(ch Channel, e *Event)) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.onChange = fn
+}
+
+// HandleEvent update channel state, events other than ChannelEvents are ignored
+func (t *ChannelTracker) HandleEvent(e *Event) {
+	if !StringInSlice(e.GetName(), ChannelEvents) {
+		return
+	}
+	uuid := e.ChannelUUID()
+	if len(uuid) == 0 {
+		return
+	}
+
+	t.Lock()
+	ch, ok := t.channels[uuid]
+	if !ok {
+		ch = &Channel{
+			UUID:      uuid,
+			Variables: make(map[string]string),
+		}
+		t.channels[uuid] = ch
+	}
+	ch.update(e)
+	snapshot := ch.clone()
+	if e.GetName() == EventChannelHangupComplete {
+		delete(t.channels, uuid)
+	}
+	onChange := t.onChange
+	t.Unlock()
+
+	if onChange != nil {
+		onChange(snapshot, e)
+	}
+}
+
+// Channel get a snapshot of channel by uuid
+func (t *ChannelTracker) Channel(uuid string) (Channel, bool) {
+	t.RLock()
+	defer t.RUnlock()
+
+	ch, ok := t.channels[uuid]
+	if !ok {
+		return Channel{}, false
+	}
+	return ch.clone(), true
+}
+
+// Channels get snapshots of all live channels
+func (t *ChannelTracker) Channels() []Channel {
+	t.RLock()
+	defer t.RUnlock()
+
+	channels := make([]Channel, 0, len(t.channels))
+	for _, ch := range t.channels {
+		channels = append(channels, ch.clone())
+	}
+	return channels
+}
+
+// TrackChannels start maintaining channel state on this connection. Inbound connections subscribe
+// ChannelEvents, outbound connections rely on `myevents`. Calling it again returns the same tracker.
+func (c *Connection) TrackChannels(ctx context.Context) (*ChannelTracker, error) {
+	c.filter.mtx.Lock()
+	if c.filter.tracker == nil {
+		c.filter.tracker = NewChannelTracker()
+	}
+	tracker := c.filter.tracker
+	c.filter.mtx.Unlock()
+
+	if c.outbound {
+		return tracker, nil
+	}
+	return tracker, c.EnableEvent(ctx, ChannelEvents...)
+}
+
+// Channel get a snapshot of a channel tracked by TrackChannels
+func (c *Connection) Channel(uuid string) (Channel, bool) {
+	c.filter.mtx.RLock()
+	tracker := c.filter.tracker
+	c.filter.mtx.RUnlock()
+
+	if tracker == nil {
+		return Channel{}, false
+	}
+	return tracker.Channel(uuid)
+}
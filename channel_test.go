@@ -0,0 +1,183 @@
+package esl
+
+import (
+	"context"
+	"net/textproto"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func newChannelEvent(name, uuid string, kv ...string) *Event {
+	e := &Event{Headers: make(textproto.MIMEHeader)}
+	e.Headers.Set("Event-Name", name)
+	e.Headers.Set("Unique-ID", uuid)
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.Headers.Set(kv[i], kv[i+1])
+	}
+	return e
+}
+
+func TestChannelTracker_HandleEvent(t *testing.T) {
+	const a, b = "a-leg", "b-leg"
+	tracker := NewChannelTracker()
+
+	tracker.HandleEvent(newChannelEvent(EventChannelCreate, a,
+		"Channel-State", "CS_INIT",
+		"Channel-Call-State", "DOWN",
+		"Answer-State", "ringing",
+		"Call-Direction", "inbound",
+		"Caller-Caller-ID-Name", "Tom%20Jerry",
+		"Caller-Caller-ID-Number", "1000",
+		"Caller-Destination-Number", "9999",
+		"Caller-Channel-Created-Time", "1589448930194500",
+		"Caller-Channel-Answered-Time", "0",
+		"variable_sip_call_id", "abc%40host",
+	))
+	ch, ok := tracker.Channel(a)
+	if !ok {
+		t.Fatal("channel not tracked after CHANNEL_CREATE")
+	}
+	if ch.State != ChannelStateInit || ch.CallState != CallStateDown || ch.AnswerState != AnswerStateRinging {
+		t.Errorf("states = %s %s %s", ch.State, ch.CallState, ch.AnswerState)
+	}
+	if ch.CallerIDName != "Tom Jerry" || ch.CallerIDNumber != "1000" || ch.DestinationNumber != "9999" {
+		t.Errorf("caller = %q %q %q", ch.CallerIDName, ch.CallerIDNumber, ch.DestinationNumber)
+	}
+	if ch.CreatedAt != time.Unix(1589448930, 194500000) || ch.Answered() {
+		t.Errorf("CreatedAt = %v, Answered = %v", ch.CreatedAt, ch.Answered())
+	}
+	if ch.GetVariable("sip_call_id") != "abc@host" {
+		t.Errorf("sip_call_id = %q", ch.GetVariable("sip_call_id"))
+	}
+
+	tracker.HandleEvent(newChannelEvent(EventChannelAnswer, a,
+		"Channel-State", "CS_EXECUTE",
+		"Answer-State", "answered",
+		"Caller-Channel-Answered-Time", "1589448931000000",
+	))
+	tracker.HandleEvent(newChannelEvent(EventChannelCallState, a, "Channel-Call-State", "ACTIVE"))
+	tracker.HandleEvent(newChannelEvent(EventChannelBridge, a,
+		"Bridge-A-Unique-ID", a,
+		"Bridge-B-Unique-ID", b,
+		"Event-Date-Timestamp", "1589448932000000",
+	))
+	ch, _ = tracker.Channel(a)
+	if !ch.Answered() || ch.CallState != CallStateActive || ch.State != ChannelStateExecute {
+		t.Errorf("after answer: %#v", ch)
+	}
+	if !ch.Bridged() || ch.BridgedUUID != b || ch.BridgedAt.IsZero() {
+		t.Errorf("after bridge: BridgedUUID = %q, BridgedAt = %v", ch.BridgedUUID, ch.BridgedAt)
+	}
+	// snapshots are not affected by later updates
+	ch.Variables["sip_call_id"] = "changed"
+
+	tracker.HandleEvent(newChannelEvent(EventChannelUnbridge, a))
+	tracker.HandleEvent(newChannelEvent(EventChannelHangup, a,
+		"Hangup-Cause", "NORMAL_CLEARING",
+		"Channel-State", "CS_HANGUP",
+		"Caller-Channel-Hangup-Time", "1589448940000000",
+	))
+	ch, _ = tracker.Channel(a)
+	if ch.Bridged() || ch.HangupCause != "NORMAL_CLEARING" || ch.HungupAt != time.Unix(1589448940, 0) {
+		t.Errorf("after hangup: %#v", ch)
+	}
+	if ch.GetVariable("sip_call_id") != "abc@host" {
+		t.Errorf("snapshot leaked into tracker: sip_call_id = %q", ch.GetVariable("sip_call_id"))
+	}
+
+	var final Channel
+	tracker.OnChange(func(ch Channel, e *Event) {
+		final = ch
+	})
+	tracker.HandleEvent(newChannelEvent(EventChannelHangupComplete, a, "Channel-State", "CS_REPORTING"))
+	if _, ok := tracker.Channel(a); ok {
+		t.Error("channel still tracked after CHANNEL_HANGUP_COMPLETE")
+	}
+	if final.UUID != a || final.State != ChannelStateReporting {
+		t.Errorf("final snapshot = %#v", final)
+	}
+
+	tracker.HandleEvent(newChannelEvent("HEARTBEAT", b))
+	if len(tracker.Channels()) != 0 {
+		t.Errorf("Channels() = %#v", tracker.Channels())
+	}
+}
+
+func TestClient_TrackChannels(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "json", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tracker, err := client.TrackChannels(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WaitCommand(ctx, "event json CHANNEL_CREATE"); err != nil {
+		t.Fatal(err)
+	}
+
+	answered := make(chan Channel, 1)
+	tracker.OnChange(func(ch Channel, e *Event) {
+		if e.GetName() == EventChannelAnswer {
+			answered <- ch
+		}
+	})
+	conn.SendEvent(esltest.NewEvent(EventChannelCreate, "Unique-ID", "c1", "Caller-Caller-ID-Number", "1000"))
+	conn.SendEvent(esltest.NewEvent(EventChannelAnswer, "Unique-ID", "c1", "Answer-State", "answered"))
+
+	select {
+	case ch := <-answered:
+		if ch.CallerIDNumber != "1000" || ch.AnswerState != AnswerStateAnswered {
+			t.Errorf("channel = %#v", ch)
+		}
+	case <-ctx.Done():
+		t.Fatal("CHANNEL_ANSWER not tracked")
+	}
+	if ch, ok := client.Channel("c1"); !ok || ch.UUID != "c1" {
+		t.Errorf("Channel(c1) = %#v, %v", ch, ok)
+	}
+}
+
+func TestClient_TrackChannelsFromHandler(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "json", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	tracked := make(chan error, 1)
+	client.FilterEvent("HEARTBEAT", func(e *Event) {
+		_, err := client.TrackChannels(ctx)
+		tracked <- err
+	})
+	conn.SendEvent(esltest.NewEvent("HEARTBEAT"))
+	select {
+	case err := <-tracked:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-ctx.Done():
+		t.Fatal("TrackChannels deadlocked in a handler")
+	}
+
+	stopped := make(chan struct{})
+	go func() {
+		client.Stop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+	case <-ctx.Done():
+		t.Fatal("Stop hung")
+	}
+}
@@ -7,9 +7,10 @@
 package esl
 
 import (
-	"bufio"
 	"context"
+	"crypto/tls"
 	"errors"
+	"fmt"
 	"net"
 	"net/textproto"
 	"strconv"
@@ -17,46 +18,75 @@ import (
 	"time"
 
 	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/command/call"
+	"github.com/zhifeichen/log"
 )
 
 // Client - In case you need to do inbound dialing against freeswitch server in order to originate call or see
 // sofia statuses or whatever else you came up with
 type Client struct {
-	Connection
-
 	Proto   string `json:"freeswitch_protocol"`
 	Addr    string `json:"freeswitch_addr"`
 	Passwd  string `json:"freeswitch_password"`
 	Timeout int    `json:"freeswitch_connection_timeout"`
+	// User authenticate with userauth user:Passwd instead of auth Passwd
+	User string `json:"freeswitch_user"`
+	// TLSConfig dial over TLS when set, with Certificates for mutual TLS
+	TLSConfig *tls.Config `json:"-"`
+	// DialTimeout dial timeout, Timeout seconds when zero
+	DialTimeout time.Duration `json:"-"`
+	// KeepAlive tcp keepalive period, zero uses the net.Dialer default and negative disables it
+	KeepAlive time.Duration `json:"-"`
+	// Reconnect backoff between reconnect attempts, zero fields use DefaultReconnectPolicy
+	Reconnect ReconnectPolicy `json:"-"`
+	// Dispatch run event handlers on a worker pool when Dispatch.Workers is set, see DispatchOptions
+	Dispatch DispatchOptions `json:"-"`
+
+	ctx    context.Context
+	cancel func()
+	// connMtx guards conn, filter, subscriptions and eventFormat
+	connMtx sync.RWMutex
+	// conn main connection, a new one replaces it on every reconnect
+	conn *Connection
+	// filter, subscriptions handlers, jobs and subscriptions shared by the successive connections
+	filter        *filter
+	subscriptions *subscriptions
 
-	running      bool
-	chnClosed    chan struct{}
-	eventFormat  string
-	events       string
-	sendConnCnt  int
-	sendConn     []*Connection
-	sendParamChn chan *sendParam
+	handshake   time.Duration
+	subscribed  bool
+	stateMtx    sync.Mutex
+	state       ClientState
+	sendStates  []ClientState
+	sendGens    []int
+	hooks       hooks
+	chnClosed   chan struct{}
+	eventFormat string
+	events      string
+	sendConnCnt int
+	poolOpts    PoolOptions
+	pool        *Pool
+	// log set by WithLogger, nil for the package logger
+	log *log.Logger
 }
 
-type sendParam struct {
-	ctx context.Context
-	cmd command.Command
-	fn  []EventHandler
+// logger logger of this client, the one given to WithLogger or the package logger
+func (c *Client) logger() *log.Logger {
+	if c.log != nil {
+		return c.log
+	}
+	return logger
 }
 
 // NewClient - Will initiate new client that will establish connection and attempt to authenticate
 // against connected freeswitch server
 func NewClient(host string, port uint16, passwd string, timeout, sendConnCnt int) (*Client, error) {
 	client := Client{
-		Proto:        "tcp", // Let me know if you ever need this open up lol
-		Addr:         net.JoinHostPort(host, strconv.Itoa(int(port))),
-		Passwd:       passwd,
-		Timeout:      timeout,
-		running:      false,
-		chnClosed:    make(chan struct{}, 1),
-		sendParamChn: make(chan *sendParam),
-		sendConnCnt:  sendConnCnt,
-		sendConn:     make([]*Connection, sendConnCnt),
+		Proto:       "tcp", // Let me know if you ever need this open up lol
+		Addr:        net.JoinHostPort(host, strconv.Itoa(int(port))),
+		Passwd:      passwd,
+		Timeout:     timeout,
+		chnClosed:   make(chan struct{}, 1),
+		sendConnCnt: sendConnCnt,
 	}
 
 	return &client, nil
@@ -64,69 +94,97 @@ func NewClient(host string, port uint16, passwd string, timeout, sendConnCnt int
 
 // EstablishConnection - Will attempt to establish connection against freeswitch and create new SocketConnection
 func (c *Client) EstablishConnection() error {
+	conn, err := c.establish()
+	if err != nil {
+		return err
+	}
+	c.setConn(conn)
+	return nil
+}
 
-	runningCtx, stop := context.WithCancel(context.Background())
-
-	// save orig filter
-	var origFilter *filter
-	if c.Connection.filter != nil {
-		origFilter = c.Connection.filter
-	}
-
-	c.Connection = Connection{
-		runningContext: runningCtx,
-		stop:           stop,
-		outbound:       false,
-		responseChns: map[string]chan *RawResponse{
-			TypeReply:       make(chan *RawResponse),
-			TypeAPIResponse: make(chan *RawResponse),
-			TypeEventPlain:  make(chan *RawResponse),
-			TypeEventJSON:   make(chan *RawResponse),
-			TypeEventXML:    make(chan *RawResponse),
-			TypeAuthRequest: make(chan *RawResponse),
-			TypeDisconnect:  make(chan *RawResponse),
-		},
-	}
-	if origFilter != nil {
-		c.Connection.filter = origFilter
-	} else {
-		filter := &filter{
-			bgapi:  bgFilter{cb: make(map[string]EventHandler)},
-			event:  eventFilter{cb: make(map[string]EventHandler)},
-			header: headerFilter{cb: make([]*headerFilterItem, 0, 5)},
-		}
-		c.Connection.filter = filter
+// establish dial a new main connection sharing the handlers, jobs and subscriptions of the client
+func (c *Client) establish() (*Connection, error) {
+	parent := c.ctx
+	if parent == nil {
+		parent = context.Background()
 	}
 
-	logger.Debugf("dial to %s %s...\n", c.Proto, c.Addr)
-	to := time.Duration(c.Timeout * int(time.Second))
-	conn, err := c.Dial(c.Proto, c.Addr, to)
+	c.logger().Debugf("dial to %s %s...\n", c.Proto, c.Addr)
+	nc, err := c.dial()
 	if err != nil {
-		logger.Error(err)
-		return err
+		c.logger().Error(err)
+		return nil, err
 	}
+	conn := c.newConnection(parent, nc)
 
-	c.Connection.conn = conn
-	c.Connection.reader = bufio.NewReader(conn)
-	c.Connection.header = textproto.NewReader(c.Connection.reader)
+	conn.filter.mtx.Lock()
+	if conn.filter.dispatch == nil && c.Dispatch.Workers > 0 {
+		conn.filter.dispatch = newDispatcher(c.Dispatch)
+	}
+	conn.filter.mtx.Unlock()
 
-	for i, sc := range c.sendConn {
-		sconn, err := sc.Dial(c.Proto, c.Addr, to)
-		if err != nil {
-			logger.Error(err)
-			c.Close()
-			return err
-		}
-		c.sendConn[i] = newConnect(c.runningContext, sconn, false)
+	c.logger().Infof("connect to %s success\n", nc.RemoteAddr().String())
+
+	return conn, nil
+}
+
+// newConnection main connection over nc
+func (c *Client) newConnection(ctx context.Context, nc net.Conn) *Connection {
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+
+	if c.filter == nil {
+		c.filter = newFilter()
+		c.subscriptions = newSubscriptions()
 	}
+	conn := newConnect(ctx, nc, false)
+	conn.filter = c.filter
+	conn.subscriptions = c.subscriptions
+	conn.log = c.log
+	// keep the format overridden with SetEventFormat, otherwise the one chosen at start
+	if len(c.eventFormat) > 0 {
+		conn.eventFormat = c.eventFormat
+	}
+	return conn
+}
 
-	logger.Infof("connect to %s success\n", conn.RemoteAddr().String())
+// current main connection. Before the first connect it is a closed connection sharing the client
+// handlers, so they can be registered before Start.
+func (c *Client) current() *Connection {
+	c.connMtx.RLock()
+	conn := c.conn
+	c.connMtx.RUnlock()
+	if conn != nil {
+		return conn
+	}
 
-	return nil
+	idle := c.newConnection(context.Background(), nil)
+	idle.stop()
+	idle.pending.close()
+
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+
+	if c.conn == nil {
+		c.conn = idle
+	}
+	return c.conn
+}
+
+// setConn replace the main connection, callers still holding the previous one see it closed
+func (c *Client) setConn(conn *Connection) {
+	c.connMtx.Lock()
+	defer c.connMtx.Unlock()
+
+	c.conn = conn
 }
 
 // DoAuth authenticate client against freeswitch.
 func (c *Client) DoAuth(ctx context.Context, auth command.Auth) error {
+	return c.current().doAuth(ctx, auth)
+}
+
+func (c *Connection) doAuth(ctx context.Context, auth command.Auth) error {
 	response, err := c.SendCommand(ctx, auth)
 	if err != nil {
 		return err
@@ -175,157 +233,486 @@ func (c *Client) DoAuth(ctx context.Context, auth command.Auth) error {
 // 	}
 // }
 
-func (c *Connection) runningLoop(passwd string, chn <-chan *sendParam) {
+// auth auth or userauth command of the client
+func (c *Client) auth() command.Auth {
+	return command.Auth{User: c.User, Passwd: c.Passwd}
+}
+
+// dial open a tcp or tls connection to the server
+func (c *Client) dial() (net.Conn, error) {
+	dialer := &net.Dialer{
+		Timeout:   c.DialTimeout,
+		KeepAlive: c.KeepAlive,
+	}
+	if dialer.Timeout <= 0 {
+		dialer.Timeout = c.timeout()
+	}
+	if c.TLSConfig != nil {
+		return tls.DialWithDialer(dialer, c.Proto, c.Addr, c.TLSConfig)
+	}
+	return dialer.Dial(c.Proto, c.Addr)
+}
+
+func (c *Client) timeout() time.Duration {
+	if c.handshake > 0 {
+		return c.handshake
+	}
+	if c.Timeout <= 0 {
+		return 5 * time.Second
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// connect dial and authenticate a main connection, restore its subscriptions and make it current
+func (c *Client) connect() (*Connection, error) {
+	c.setState(StateConnecting)
+	conn, err := c.establish()
+	if err != nil {
+		return nil, err
+	}
+	authChn := conn.responseChn(TypeAuthRequest)
+
+	go conn.receiveLoop()
+	go conn.eventLoop()
+
+	c.setState(StateAuthenticating)
 	select {
-	case <-c.runningContext.Done():
-		c.Close()
-		return
-	case <-c.responseChns[TypeAuthRequest]:
-		auth := command.Auth{Passwd: passwd}
-		c.SendCommand(c.runningContext, auth)
-		c.EnableEvent(c.runningContext, "BACKGROUND_JOB")
-	case <-c.responseChns[TypeDisconnect]:
-		c.Close()
-		return
+	case _, ok := <-authChn:
+		if !ok {
+			return nil, ErrConnClosed
+		}
+	case <-time.After(c.timeout()):
+		conn.Close()
+		return nil, ErrTimeout
+	case <-conn.runningContext.Done():
+		conn.Close()
+		return nil, ErrConnClosed
+	}
+
+	ctx, cancel := context.WithTimeout(conn.runningContext, c.timeout())
+	defer cancel()
+	if err := conn.doAuth(ctx, c.auth()); err != nil {
+		if errors.Is(err, ErrInvalidPassword) {
+			c.authFailed(MainConnection, err)
+		}
+		conn.Close()
+		return nil, err
 	}
+
+	if !c.subscribed {
+		// the first connection subscribes the events given to Start, later ones replay what was recorded
+		err = conn.EnableEvent(ctx, c.events)
+		c.subscribed = err == nil
+	} else {
+		err = conn.replaySubscriptions(ctx)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	c.setConn(conn)
+	return conn, nil
+}
+
+// serve wait until the main connection conn is lost
+func (c *Client) serve(conn *Connection) error {
+	authChn := conn.responseChn(TypeAuthRequest)
+	disconnectChn := conn.responseChn(TypeDisconnect)
 	for {
 		select {
-		case <-c.runningContext.Done():
-			c.Close()
-			return
-		case <-c.responseChns[TypeAuthRequest]:
-			auth := command.Auth{Passwd: passwd}
-			c.SendCommand(c.runningContext, auth)
-			// c.EnableEvent(c.runningContext, "BACKGROUND_JOB")
-		case <-c.responseChns[TypeDisconnect]:
-			c.Close()
-			return
-		case cd, ok := <-chn:
+		case _, ok := <-authChn:
 			if !ok {
-				continue
+				return ErrConnClosed
 			}
-			resp, err := c.SendCommand(cd.ctx, cd.cmd, cd.fn...)
+			ctx, cancel := context.WithTimeout(conn.runningContext, c.timeout())
+			err := conn.doAuth(ctx, c.auth())
+			cancel()
 			if err != nil {
-				logger.Error(err)
-				continue
-			}
-			logger.Info(resp)
-			if bgCmd, ok := cd.cmd.(command.API); !ok || !bgCmd.Background {
-				if len(cd.fn) > 0 {
-					e := &Event{
-						Headers: make(textproto.MIMEHeader),
-					}
-					for k, v := range resp.Headers {
-						for _, vv := range v {
-							e.Headers.Add(k, vv)
-						}
-					}
-					copy(e.Body, resp.Body)
-					cd.fn[0](e)
+				c.logger().Errorf("authenticate %s error: %s\n", c.Addr, err.Error())
+				if errors.Is(err, ErrInvalidPassword) {
+					c.authFailed(MainConnection, err)
 				}
+				conn.Close()
+				return err
 			}
+			c.logger().Infof("successfully authenticated %s\n", c.Addr)
+		case <-disconnectChn:
+			conn.Close()
+			c.logger().Warnf("connection disconnected\n")
+			return ErrConnClosed
+		case <-conn.runningContext.Done():
+			conn.Close()
+			return ErrConnClosed
 		}
 	}
 }
 
-func (c *Client) loop(connected chan<- struct{}) {
-	var once sync.Once
-	for c.running {
-		err := c.EstablishConnection()
-		if err != nil {
-			<-time.After(2 * time.Second)
-			continue
-		}
-
-		go c.receiveLoop()
-		go c.eventLoop()
+// loop keep the client connected until stopped. Failed attempts are retried with backoff, except
+// an authentication failure of the first attempt which is reported to Start.
+func (c *Client) loop(connected chan<- error) {
+	defer func() {
+		c.chnClosed <- struct{}{}
+	}()
 
-		<-c.responseChns[TypeAuthRequest]
-		err = c.DoAuth(c.runningContext, command.Auth{Passwd: c.Passwd})
-		if err != nil {
-			c.Close()
-			panic("auth failue")
+	first := true
+	attempt := 0
+	for {
+		conn, err := c.connect()
+		if err == nil {
+			attempt = 0
+			if first {
+				first = false
+				connected <- nil
+			}
+			c.setState(StateConnected)
+			err = c.serve(conn)
 		}
-
-		once.Do(func() {
-			connected <- struct{}{}
-		})
-		c.EnableEvent(c.runningContext, c.events)
-		for _, sc := range c.sendConn {
-			go sc.runningLoop(c.Passwd, c.sendParamChn)
-			go sc.receiveLoop()
-			go sc.eventLoop()
-
-			// go sc.sendLoop(c.sendParamChn)
+		c.setConnState(MainConnection, -1, StateDisconnected, err)
+		if c.ctx.Err() != nil {
+			return
+		}
+		if first && errors.Is(err, ErrInvalidPassword) {
+			connected <- err
+			return
 		}
 
+		delay := c.Reconnect.Delay(attempt)
+		attempt++
+		c.logger().Warnf("connection to %s lost: %v, reconnect in %s\n", c.Addr, err, delay)
+		c.setState(StateReconnecting)
 		select {
-		case <-c.responseChns[TypeAuthRequest]:
-			err := c.DoAuth(c.runningContext, command.Auth{Passwd: c.Passwd})
-			if err != nil {
-				addr := "nil net addr"
-				if c.conn != nil {
-					addr = c.conn.RemoteAddr().String()
-				}
-				logger.Errorf("authenticate %s error: %s\n", addr, err.Error())
-				c.ExitAndClose()
-				return
-			}
-			logger.Infof("successfully authenticated %s\n", c.conn.RemoteAddr())
-		case <-c.responseChns[TypeDisconnect]:
-			c.Close()
-			logger.Warnf("connection disconnected\n")
-			continue
-		case <-c.runningContext.Done():
-			logger.Debug("run context done")
-			c.chnClosed <- struct{}{}
+		case <-time.After(delay):
+		case <-c.ctx.Done():
 			return
 		}
 	}
-	c.chnClosed <- struct{}{}
 }
 
-// Start start process loop
+// Start start process loop, subscribe events in format (plain, json or xml) on every connection.
+// The client reconnects until Stop, restoring events, filters, myevents, divert_events and log level.
 func (c *Client) Start(format, events string) error {
-	if c.running {
+	ctx, cancel := context.WithTimeout(context.Background(), 2*c.timeout())
+	defer cancel()
+	return c.start(ctx, format, events)
+}
+
+// start start process loop and wait until the first connection is ready or ctx is done
+func (c *Client) start(ctx context.Context, format, events string) error {
+	if c.cancel != nil {
 		return nil
 	}
-	c.running = true
-	connected := make(chan struct{})
+	if len(format) == 0 {
+		format = EventFormatPlain
+	}
+	if !validEventFormat(format) {
+		return ErrUnsupportedEventFormat
+	}
+	c.connMtx.Lock()
 	c.eventFormat = format
+	c.connMtx.Unlock()
 	c.events = events
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	connected := make(chan error, 1)
 	go c.loop(connected)
+
+	var err error
 	select {
-	case <-connected:
-		return nil
-	case <-time.After(time.Duration(c.Timeout*2) * time.Second):
-		c.running = false
-		// c.Close()
-		return errors.New("connect timeout")
+	case err = <-connected:
+	case <-ctx.Done():
+		err = fmt.Errorf("connect timeout: %w", ctx.Err())
+	}
+	if err != nil {
+		c.cancel()
+		<-c.chnClosed
+		c.cancel = nil
+		c.setState(StateDisconnected)
+		return err
 	}
+	if c.sendConnCnt > 0 {
+		opts := c.poolOpts
+		opts.Size = c.sendConnCnt
+		if opts.Reconnect == (ReconnectPolicy{}) {
+			opts.Reconnect = c.Reconnect
+		}
+		c.pool = newPool(c.DialConn, opts, c.reportSend)
+	}
+	return nil
 }
 
 // Stop stop process loop
 func (c *Client) Stop() {
-	c.running = false
-	c.stop()
-	c.Close()
-	close(c.sendParamChn)
-	logger.Info("close done")
+	if c.cancel == nil {
+		return
+	}
+	c.cancel()
 	<-c.chnClosed
-	logger.Info("done")
+	c.current().Close()
+	c.stopDispatch()
+	if c.pool != nil {
+		c.pool.Close()
+		c.pool = nil
+	}
+	c.cancel = nil
+	c.stopSendStates()
+	c.setState(StateStopped)
+	c.logger().Info("done")
+}
+
+// stopDispatch stop the dispatcher, queued events are still delivered
+func (c *Client) stopDispatch() {
+	f := c.current().filter
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if f.dispatch != nil {
+		f.dispatch.close()
+		f.dispatch = nil
+	}
 }
 
+// SendCommand2 send cmd on a send connection without waiting, errors are logged. fn is called with the
+// BACKGROUND_JOB of a bgapi command, or with the reply of other commands. Without send connections cmd
+// is sent on the main connection and waited for.
+//
+// Deprecated: use Pool().Do, which returns the reply and the error.
 func (c *Client) SendCommand2(ctx context.Context, cmd command.Command, fn ...EventHandler) {
-	if c.sendConnCnt == 0 {
+	if c.pool == nil {
 		c.SendCommand(ctx, cmd, fn...)
 		return
 	}
-	cd := sendParam{
-		ctx: ctx,
-		cmd: cmd,
-		fn:  make([]EventHandler, 0),
+	pool := c.pool
+	go func() {
+		resp, err := pool.Do(ctx, cmd, fn...)
+		if err != nil {
+			c.logger().Errorf("send command %s error: %s\n", cmd.BuildMessage(), err.Error())
+			return
+		}
+		if bgCmd, ok := cmd.(command.API); (!ok || !bgCmd.Background) && len(fn) > 0 {
+			fn[0](replyEvent(resp))
+		}
+	}()
+}
+
+// replyEvent reply as an event, for the callbacks of SendCommand2
+func replyEvent(resp *RawResponse) *Event {
+	e := &Event{
+		Headers: make(textproto.MIMEHeader),
+		Body:    make([]byte, len(resp.Body)),
+	}
+	for k, v := range resp.Headers {
+		e.Headers[k] = append([]string(nil), v...)
+	}
+	copy(e.Body, resp.Body)
+	return e
+}
+
+// Pool pool of the send connections, nil without send connections or before Start
+func (c *Client) Pool() *Pool {
+	return c.pool
+}
+
+// DialConn open an extra authenticated connection to the server of the client, subscribed to BACKGROUND_JOB
+// in the client event format so bgapi callbacks work. Use it as the PoolDialer of a Pool.
+func (c *Client) DialConn(ctx context.Context) (*Connection, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, c.timeout())
+		defer cancel()
+	}
+	nc, err := c.dial()
+	if err != nil {
+		return nil, err
+	}
+	conn := newConnect(context.Background(), nc, false)
+	conn.log = c.log
+	c.connMtx.RLock()
+	if len(c.eventFormat) > 0 {
+		conn.eventFormat = c.eventFormat
+	}
+	c.connMtx.RUnlock()
+	authChn := conn.responseChn(TypeAuthRequest)
+	go conn.receiveLoop()
+	go conn.eventLoop()
+
+	select {
+	case _, ok := <-authChn:
+		if !ok {
+			return nil, ErrConnClosed
+		}
+	case <-ctx.Done():
+		conn.Close()
+		return nil, ctx.Err()
+	}
+	if err := conn.doAuth(ctx, c.auth()); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := conn.EnableEvent(ctx, "BACKGROUND_JOB"); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return conn, nil
+}
+
+// reportSend state reporter of the send connections in the pool
+func (c *Client) reportSend(i int, state ClientState, err error) {
+	if state == StateConnecting {
+		c.newSendGeneration(i)
+		return
+	}
+	c.sendReporter(i)(state, err)
+}
+
+// Dial see Connection.Dial
+func (c *Client) Dial(network string, addr string, timeout time.Duration) (net.Conn, error) {
+	return c.current().Dial(network, addr, timeout)
+}
+
+// RemoteAddr remote addr of the main connection
+func (c *Client) RemoteAddr() net.Addr {
+	return c.current().RemoteAddr()
+}
+
+// LocalAddr local addr of the main connection
+func (c *Client) LocalAddr() net.Addr {
+	return c.current().LocalAddr()
+}
+
+// ExitAndClose send exit and close the main connection, the client reconnects until Stop
+func (c *Client) ExitAndClose() {
+	c.current().ExitAndClose()
+}
+
+// Close close the main connection, the client reconnects until Stop
+func (c *Client) Close() {
+	c.current().Close()
+}
+
+// SendCommand send cmd on the main connection, see Connection.SendCommand. Commands sent while the
+// client reconnects fail with ErrConnClosed.
+func (c *Client) SendCommand(ctx context.Context, cmd command.Command, fn ...EventHandler) (*RawResponse, error) {
+	return c.current().SendCommand(ctx, cmd, fn...)
+}
+
+// SetEventFormat set the format subscribed by the next EnableEvent, kept across reconnects
+func (c *Client) SetEventFormat(format string) error {
+	if !validEventFormat(format) {
+		return ErrUnsupportedEventFormat
 	}
-	cd.fn = append(cd.fn, fn...)
-	c.sendParamChn <- &cd
+	c.connMtx.Lock()
+	c.eventFormat = format
+	c.connMtx.Unlock()
+	return c.current().SetEventFormat(format)
+}
+
+// EventFormat see Connection.EventFormat
+func (c *Client) EventFormat() string {
+	return c.current().EventFormat()
+}
+
+// EnableEvent see Connection.EnableEvent
+func (c *Client) EnableEvent(ctx context.Context, events ...string) error {
+	return c.current().EnableEvent(ctx, events...)
+}
+
+// Set see Connection.Set
+func (c *Client) Set(ctx context.Context, key, value, uuid string) error {
+	return c.current().Set(ctx, key, value, uuid)
+}
+
+// Export see Connection.Export
+func (c *Client) Export(ctx context.Context, key, value, uuid string) error {
+	return c.current().Export(ctx, key, value, uuid)
+}
+
+// Originate see Connection.Originate
+func (c *Client) Originate(ctx context.Context, o call.Originate) (string, error) {
+	return c.current().Originate(ctx, o)
+}
+
+// StartBgAPI see Connection.StartBgAPI
+func (c *Client) StartBgAPI(ctx context.Context, cmd, args string) (*Job, error) {
+	return c.current().StartBgAPI(ctx, cmd, args)
+}
+
+// BgAPI see Connection.BgAPI
+func (c *Client) BgAPI(ctx context.Context, cmd, args string) (*JobResult, error) {
+	return c.current().BgAPI(ctx, cmd, args)
+}
+
+// SetJobTTL see Connection.SetJobTTL
+func (c *Client) SetJobTTL(ttl time.Duration) {
+	c.current().SetJobTTL(ttl)
+}
+
+// JobStats see Connection.JobStats
+func (c *Client) JobStats() JobStats {
+	return c.current().JobStats()
+}
+
+// ExecuteWait see Connection.ExecuteWait
+func (c *Client) ExecuteWait(ctx context.Context, uuid, app, args string) (*ExecuteResult, error) {
+	return c.current().ExecuteWait(ctx, uuid, app, args)
+}
+
+// Call media helpers of channel uuid, they keep working across reconnects
+func (c *Client) Call(uuid string) *Call {
+	return &Call{conn: c, UUID: uuid}
+}
+
+// DTMF see Connection.DTMF, the reader ends when the main connection is lost
+func (c *Client) DTMF(uuid string, buffer int) *DTMFReader {
+	return c.current().DTMF(uuid, buffer)
+}
+
+// TrackChannels see Connection.TrackChannels, the tracker is kept across reconnects
+func (c *Client) TrackChannels(ctx context.Context) (*ChannelTracker, error) {
+	return c.current().TrackChannels(ctx)
+}
+
+// Channel see Connection.Channel
+func (c *Client) Channel(uuid string) (Channel, bool) {
+	return c.current().Channel(uuid)
+}
+
+// DispatchStats see Connection.DispatchStats
+func (c *Client) DispatchStats() DispatchStats {
+	return c.current().DispatchStats()
+}
+
+// FilterEvent see Connection.FilterEvent, handlers are kept across reconnects
+func (c *Client) FilterEvent(name string, cb EventHandler) *Subscription {
+	return c.current().FilterEvent(name, cb)
+}
+
+// FilterHeader see Connection.FilterHeader
+func (c *Client) FilterHeader(header, value string, cb EventHandler) *Subscription {
+	return c.current().FilterHeader(header, value, cb)
+}
+
+// FilterMatch see Connection.FilterMatch
+func (c *Client) FilterMatch(m Matcher, cb EventHandler) *Subscription {
+	return c.current().FilterMatch(m, cb)
+}
+
+// RemoveFilterEvent see Connection.RemoveFilterEvent
+func (c *Client) RemoveFilterEvent(name string) {
+	c.current().RemoveFilterEvent(name)
+}
+
+// RemoveFilterHeader see Connection.RemoveFilterHeader
+func (c *Client) RemoveFilterHeader(ctx context.Context, header, value string) error {
+	return c.current().RemoveFilterHeader(ctx, header, value)
+}
+
+// FilterChannel see Connection.FilterChannel, Unsubscribe deletes the server side filter on the
+// connection current at that time
+func (c *Client) FilterChannel(ctx context.Context, uuid string, cb EventHandler) (*Subscription, error) {
+	return filterChannel(ctx, c.current, uuid, cb)
+}
+
+// AddServerFilter see Connection.AddServerFilter
+func (c *Client) AddServerFilter(ctx context.Context, m Matcher) error {
+	return c.current().AddServerFilter(ctx, m)
 }
@@ -9,11 +9,16 @@ type API struct {
 	Command    string
 	Arguments  string
 	Background bool
+	// JobUUID preset the Job-UUID of a background job
+	JobUUID string
 }
 
 // BuildMessage Implement API command interface
 func (api API) BuildMessage() string {
 	if api.Background {
+		if len(api.JobUUID) > 0 {
+			return fmt.Sprintf("bgapi %s %s\r\nJob-UUID: %s", api.Command, api.Arguments, api.JobUUID)
+		}
 		return fmt.Sprintf("bgapi %s %s", api.Command, api.Arguments)
 	}
 	return fmt.Sprintf("api %s %s", api.Command, api.Arguments)
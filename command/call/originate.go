@@ -0,0 +1,148 @@
+package call
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// Endpoint one leg of an originate dial string, e.g. sofia/gateway/gw1/123 or user/1000
+type Endpoint struct {
+	DialString string
+	// Variables leg only channel variables, rendered as [k=v]
+	Variables map[string]string
+}
+
+// ErrNoTarget an Originate with neither App nor Extension
+var ErrNoTarget = errors.New("originate needs an App or an Extension")
+
+// Originate `bgapi originate` command builder:
+//
+//	originate {global}[leg]endpoint,[leg]endpoint|[leg]endpoint &app(args)
+//	originate {global}[leg]endpoint extension dialplan context
+type Originate struct {
+	// UUID preassigned uuid of the new channel (origination_uuid)
+	UUID string
+	// Variables global channel variables, rendered as {k=v}
+	Variables map[string]string
+	// Endpoints `|` separated failover groups of `,` separated legs ringing at the same time
+	Endpoints [][]Endpoint
+
+	// App application executed once answered, used instead of Extension when set
+	App     string
+	AppArgs string
+	// Extension dialplan target with optional Dialplan (default XML) and Context (default default)
+	Extension string
+	Dialplan  string
+	Context   string
+
+	CallerIDName   string
+	CallerIDNumber string
+	// Timeout seconds to wait for answer (originate_timeout)
+	Timeout int
+}
+
+// Validate Implement command.Validator, one of App and Extension is required
+func (o Originate) Validate() error {
+	if len(o.App) == 0 && len(o.Extension) == 0 {
+		return ErrNoTarget
+	}
+	return nil
+}
+
+// BuildMessage Implement command interface
+func (o Originate) BuildMessage() string {
+	return command.API{
+		Command:    "originate",
+		Arguments:  o.Arguments(),
+		Background: true,
+	}.BuildMessage()
+}
+
+// Arguments originate api arguments
+func (o Originate) Arguments() string {
+	args := []string{o.DialString()}
+	if len(o.App) > 0 {
+		target := fmt.Sprintf("&%s(%s)", o.App, o.AppArgs)
+		if strings.ContainsAny(target, " '") {
+			target = quote(target)
+		}
+		return strings.Join(append(args, target), " ")
+	}
+
+	dialplan, context := o.Dialplan, o.Context
+	if len(dialplan) == 0 {
+		dialplan = "XML"
+	}
+	if len(context) == 0 {
+		context = "default"
+	}
+	return strings.Join(append(args, o.Extension, dialplan, context), " ")
+}
+
+// DialString render the dial string with global and per leg variables
+func (o Originate) DialString() string {
+	vars := make(map[string]string, len(o.Variables)+4)
+	for k, v := range o.Variables {
+		vars[k] = v
+	}
+	if len(o.UUID) > 0 {
+		vars["origination_uuid"] = o.UUID
+	}
+	if len(o.CallerIDName) > 0 {
+		vars["origination_caller_id_name"] = o.CallerIDName
+	}
+	if len(o.CallerIDNumber) > 0 {
+		vars["origination_caller_id_number"] = o.CallerIDNumber
+	}
+	if o.Timeout > 0 {
+		vars["originate_timeout"] = strconv.Itoa(o.Timeout)
+	}
+
+	var builder strings.Builder
+	if len(vars) > 0 {
+		builder.WriteString("{" + renderVariables(vars) + "}")
+	}
+	for i, group := range o.Endpoints {
+		if i > 0 {
+			builder.WriteString("|")
+		}
+		for j, leg := range group {
+			if j > 0 {
+				builder.WriteString(",")
+			}
+			if len(leg.Variables) > 0 {
+				builder.WriteString("[" + renderVariables(leg.Variables) + "]")
+			}
+			builder.WriteString(leg.DialString)
+		}
+	}
+	return builder.String()
+}
+
+// renderVariables render k=v pairs sorted by key, commas escaped and values with spaces or quotes single quoted
+func renderVariables(vars map[string]string) string {
+	keys := make([]string, 0, len(vars))
+	for k := range vars {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, 0, len(keys))
+	for _, k := range keys {
+		v := strings.ReplaceAll(vars[k], ",", `\,`)
+		if strings.ContainsAny(v, " '") {
+			v = quote(v)
+		}
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func quote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `\'`) + "'"
+}
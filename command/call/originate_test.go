@@ -0,0 +1,70 @@
+package call
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestOriginate_BuildMessage(t *testing.T) {
+	tests := []struct {
+		name string
+		o    Originate
+		want string
+	}{
+		{"park", Originate{
+			Endpoints: [][]Endpoint{{{DialString: "user/1000"}}},
+			App:       "park",
+		}, "bgapi originate user/1000 &park()"},
+		{"extension", Originate{
+			Endpoints: [][]Endpoint{{{DialString: "sofia/gateway/gw1/123"}}},
+			Extension: "9999",
+		}, "bgapi originate sofia/gateway/gw1/123 9999 XML default"},
+		{"extension context", Originate{
+			Endpoints: [][]Endpoint{{{DialString: "user/1000"}}},
+			Extension: "9999",
+			Dialplan:  "XML",
+			Context:   "public",
+		}, "bgapi originate user/1000 9999 XML public"},
+		{"variables", Originate{
+			UUID:           "f81d4fae-7dec-41d0-a765-00a0c91e6bf6",
+			Variables:      map[string]string{"ignore_early_media": "true", "sip_h_X-List": "a,b"},
+			CallerIDName:   "John O'Neil",
+			CallerIDNumber: "1000",
+			Timeout:        30,
+			Endpoints:      [][]Endpoint{{{DialString: "user/1001"}}},
+			App:            "bridge",
+			AppArgs:        "user/1002",
+		}, `bgapi originate {ignore_early_media=true,originate_timeout=30,origination_caller_id_name='John O\'Neil',` +
+			`origination_caller_id_number=1000,origination_uuid=f81d4fae-7dec-41d0-a765-00a0c91e6bf6,` +
+			`sip_h_X-List=a\,b}user/1001 &bridge(user/1002)`},
+		{"legs", Originate{
+			Endpoints: [][]Endpoint{
+				{
+					{DialString: "user/1000", Variables: map[string]string{"leg_timeout": "10"}},
+					{DialString: "user/1001"},
+				},
+				{
+					{DialString: "sofia/gateway/gw1/123", Variables: map[string]string{"absolute_codec_string": "PCMU,PCMA"}},
+				},
+			},
+			App:     "playback",
+			AppArgs: "/tmp/hello world.wav",
+		}, `bgapi originate [leg_timeout=10]user/1000,user/1001|[absolute_codec_string=PCMU\,PCMA]sofia/gateway/gw1/123 ` +
+			`'&playback(/tmp/hello world.wav)'`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.o.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if got := tt.o.BuildMessage(); got != tt.want {
+				t.Errorf("BuildMessage() =\n%s\nwant\n%s", got, tt.want)
+			}
+		})
+	}
+
+	noTarget := Originate{Endpoints: [][]Endpoint{{{DialString: "user/1000"}}}, Context: "public"}
+	if err := noTarget.Validate(); !errors.Is(err, ErrNoTarget) {
+		t.Errorf("Validate() = %v, want %v", err, ErrNoTarget)
+	}
+}
@@ -4,3 +4,9 @@ package command
 type Command interface {
 	BuildMessage() string
 }
+
+// Validator implemented by commands which cannot be built from every value, SendCommand refuses
+// them when Validate fails
+type Validator interface {
+	Validate() error
+}
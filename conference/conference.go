@@ -0,0 +1,169 @@
+// Package conference controls mod_conference rooms with `conference` api commands and keeps a live
+// roster of their members from conference::maintenance events.
+package conference
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2"
+	"github.com/zhifeichen/esl/v2/api"
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// member selectors accepted by the member commands besides a member id
+const (
+	All          = "all"
+	Last         = "last"
+	NonModerator = "non_moderator"
+)
+
+// Conference one room, the commands fail with an error wrapping esl.ErrCommandFailed when
+// FreeSWITCH refuses them, e.g. the room or the member does not exist
+type Conference struct {
+	s    api.Sender
+	Name string
+}
+
+// New control room name through s
+func New(s api.Sender, name string) *Conference {
+	return &Conference{s: s, Name: name}
+}
+
+// send issue `api conference <name> <args>`
+func (c *Conference) send(ctx context.Context, args string) (string, error) {
+	resp, err := c.s.SendCommand(ctx, command.API{Command: "conference", Arguments: c.Name + " " + args})
+	if err != nil {
+		return "", err
+	}
+	body := strings.TrimRight(string(resp.Body), "\r\n")
+	if failed(body) {
+		return "", fmt.Errorf("%s: %w", body, esl.ErrCommandFailed)
+	}
+	return body, nil
+}
+
+// failed mod_conference replies are not consistently prefixed with -ERR
+func failed(body string) bool {
+	return strings.HasPrefix(body, "-ERR") || strings.HasPrefix(body, "-USAGE") ||
+		strings.HasPrefix(body, "Non-Existent ID") || strings.HasPrefix(body, "Conference ") && strings.HasSuffix(body, "not found")
+}
+
+// List issue `list`, members ordered as FreeSWITCH lists them
+func (c *Conference) List(ctx context.Context) ([]Member, error) {
+	body, err := c.send(ctx, "list")
+	if err != nil {
+		return nil, err
+	}
+	return parseList(body)
+}
+
+// Kick hang up member, a member id or All, Last, NonModerator
+func (c *Conference) Kick(ctx context.Context, member string) error {
+	_, err := c.send(ctx, "kick "+member)
+	return err
+}
+
+// Mute stop sending the audio of member to the room
+func (c *Conference) Mute(ctx context.Context, member string) error {
+	_, err := c.send(ctx, "mute "+member)
+	return err
+}
+
+// Unmute undo Mute
+func (c *Conference) Unmute(ctx context.Context, member string) error {
+	_, err := c.send(ctx, "unmute "+member)
+	return err
+}
+
+// Deaf stop sending the audio of the room to member
+func (c *Conference) Deaf(ctx context.Context, member string) error {
+	_, err := c.send(ctx, "deaf "+member)
+	return err
+}
+
+// Undeaf undo Deaf
+func (c *Conference) Undeaf(ctx context.Context, member string) error {
+	_, err := c.send(ctx, "undeaf "+member)
+	return err
+}
+
+// Play play file to the whole room, or to member only when it is not empty
+func (c *Conference) Play(ctx context.Context, file, member string) error {
+	args := "play " + file
+	if member != "" {
+		args += " " + member
+	}
+	_, err := c.send(ctx, args)
+	return err
+}
+
+// Record start recording the room to path
+func (c *Conference) Record(ctx context.Context, path string) error {
+	_, err := c.send(ctx, "record "+path)
+	return err
+}
+
+// StopRecord stop the recording to path, All stops every recording of the room
+func (c *Conference) StopRecord(ctx context.Context, path string) error {
+	_, err := c.send(ctx, "norecord "+path)
+	return err
+}
+
+// Lock refuse new members
+func (c *Conference) Lock(ctx context.Context) error {
+	_, err := c.send(ctx, "lock")
+	return err
+}
+
+// Unlock undo Lock
+func (c *Conference) Unlock(ctx context.Context) error {
+	_, err := c.send(ctx, "unlock")
+	return err
+}
+
+var dialResultRe = regexp.MustCompile(`result: \[(.*)\]`)
+
+// Dial call endpoint into the room and wait for the outcome of the call
+func (c *Conference) Dial(ctx context.Context, endpoint, callerIDNumber, callerIDName string) error {
+	body, err := c.send(ctx, dialArgs("dial", endpoint, callerIDNumber, callerIDName))
+	if err != nil {
+		return err
+	}
+	m := dialResultRe.FindStringSubmatch(body)
+	if m == nil {
+		return fmt.Errorf("%s: %w", body, api.ErrUnexpectedResponse)
+	}
+	if m[1] != "SUCCESS" {
+		return fmt.Errorf("%s: %w", m[1], esl.ErrCommandFailed)
+	}
+	return nil
+}
+
+// BGDial call endpoint into the room without waiting, returns the Job-UUID of the
+// conference::maintenance bgdial-result event
+func (c *Conference) BGDial(ctx context.Context, endpoint, callerIDNumber, callerIDName string) (string, error) {
+	body, err := c.send(ctx, dialArgs("bgdial", endpoint, callerIDNumber, callerIDName))
+	if err != nil {
+		return "", err
+	}
+	i := strings.Index(body, "Job-UUID:")
+	if i < 0 {
+		return "", fmt.Errorf("%s: %w", body, api.ErrUnexpectedResponse)
+	}
+	return strings.TrimSpace(body[i+len("Job-UUID:"):]), nil
+}
+
+// dialArgs FreeSWITCH splits the arguments on spaces, a caller id name with spaces is cut at the first one
+func dialArgs(cmd, endpoint, callerIDNumber, callerIDName string) string {
+	args := cmd + " " + endpoint
+	if callerIDNumber != "" {
+		args += " " + callerIDNumber
+		if callerIDName != "" {
+			args += " " + callerIDName
+		}
+	}
+	return args
+}
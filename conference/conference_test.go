@@ -0,0 +1,118 @@
+package conference
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+const listOutput = `1;sofia/internal/1000@10.0.0.1;call-1;Alice;1000;hear|speak|talking|floor|moderator;0;0;100
+2;sofia/internal/1001@10.0.0.1;call-2;Bob;1001;hear;1;-1;300
+`
+
+// newTestClient client of a fake FreeSWITCH hosting room 3000
+func newTestClient(t *testing.T) (*esltest.Server, *esl.Client) {
+	server, err := esltest.NewServer(esltest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	server.HandleAPI("conference", func(args string) string {
+		parts := strings.SplitN(args, " ", 3)
+		if parts[0] != "3000" {
+			return "-ERR Conference " + parts[0] + " not found\n"
+		}
+		switch parts[1] {
+		case "list":
+			return listOutput
+		case "kick", "mute", "unmute", "deaf", "undeaf":
+			if parts[2] != "1" && parts[2] != All {
+				return "Non-Existent ID " + parts[2] + "\n"
+			}
+			return "OK " + parts[1] + " " + parts[2] + "\n"
+		case "dial":
+			if strings.HasPrefix(parts[2], "user/1002") {
+				return "Call Requested: result: [USER_BUSY]\n"
+			}
+			return "Call Requested: result: [SUCCESS]\n"
+		case "bgdial":
+			return "OK Job-UUID: job-1\n"
+		}
+		return "+OK\n"
+	})
+
+	client, err := esl.Dial(context.Background(), server.Addr(), esl.WithPassword(esltest.DefaultPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	return server, client
+}
+
+func TestConference_Commands(t *testing.T) {
+	server, client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	c := New(client, "3000")
+
+	members, err := c.List(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Member{
+		{ID: 1, UUID: "call-1", ChannelName: "sofia/internal/1000@10.0.0.1", CallerIDName: "Alice", CallerIDNumber: "1000",
+			Hear: true, Speak: true, Talking: true, Floor: true, Moderator: true, Energy: 100},
+		{ID: 2, UUID: "call-2", ChannelName: "sofia/internal/1001@10.0.0.1", CallerIDName: "Bob", CallerIDNumber: "1001",
+			Hear: true, VolumeIn: 1, VolumeOut: -1, Energy: 300},
+	}
+	if !reflect.DeepEqual(members, want) {
+		t.Errorf("List() = %+v, want %+v", members, want)
+	}
+
+	if err := c.Mute(ctx, "1"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Kick(ctx, "7"); !errors.Is(err, esl.ErrCommandFailed) {
+		t.Errorf("Kick(7) error = %v, want %v", err, esl.ErrCommandFailed)
+	}
+	if err := c.Play(ctx, "/tmp/hello.wav", ""); err != nil {
+		t.Error(err)
+	}
+	if err := c.Lock(ctx); err != nil {
+		t.Error(err)
+	}
+	if err := c.Dial(ctx, "user/1001", "3000", "Room"); err != nil {
+		t.Error(err)
+	}
+	if err := c.Dial(ctx, "user/1002", "", ""); !errors.Is(err, esl.ErrCommandFailed) {
+		t.Errorf("Dial(busy) error = %v, want %v", err, esl.ErrCommandFailed)
+	}
+	if job, err := c.BGDial(ctx, "user/1003", "3000", ""); err != nil || job != "job-1" {
+		t.Errorf("BGDial() = %q, %v", job, err)
+	}
+	if _, err := New(client, "4000").List(ctx); !errors.Is(err, esl.ErrCommandFailed) {
+		t.Errorf("List() of a missing room error = %v, want %v", err, esl.ErrCommandFailed)
+	}
+
+	conn, err := server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, cmd := range []string{
+		"api conference 3000 mute 1",
+		"api conference 3000 play /tmp/hello.wav",
+		"api conference 3000 lock",
+		"api conference 3000 dial user/1001 3000 Room",
+		"api conference 3000 bgdial user/1003 3000",
+	} {
+		if _, err := conn.WaitCommand(ctx, cmd); err != nil {
+			t.Errorf("%s: %v", cmd, err)
+		}
+	}
+}
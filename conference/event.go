@@ -0,0 +1,170 @@
+package conference
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2"
+	"github.com/zhifeichen/esl/v2/api"
+)
+
+// EventSubclass Event-Subclass of the CUSTOM events of mod_conference
+const EventSubclass = "conference::maintenance"
+
+// Action Action header of a conference::maintenance event
+type Action string
+
+// actions
+const (
+	ActionConferenceCreate  Action = "conference-create"
+	ActionConferenceDestroy Action = "conference-destroy"
+	ActionAddMember         Action = "add-member"
+	ActionDelMember         Action = "del-member"
+	ActionKickMember        Action = "kick-member"
+	ActionStartTalking      Action = "start-talking"
+	ActionStopTalking       Action = "stop-talking"
+	ActionMuteMember        Action = "mute-member"
+	ActionUnmuteMember      Action = "unmute-member"
+	ActionDeafMember        Action = "deaf-member"
+	ActionUndeafMember      Action = "undeaf-member"
+	ActionFloorChange       Action = "floor-change"
+	ActionEnergyLevel       Action = "energy-level"
+	ActionPlayFile          Action = "play-file"
+	ActionPlayFileDone      Action = "play-file-done"
+	ActionPlayFileMember    Action = "play-file-member"
+	ActionStartRecording    Action = "start-recording"
+	ActionStopRecording     Action = "stop-recording"
+	ActionLock              Action = "lock"
+	ActionUnlock            Action = "unlock"
+	ActionBGDialResult      Action = "bgdial-result"
+)
+
+// Member member of a room, from `list` or the member headers of an event
+type Member struct {
+	ID             int
+	UUID           string
+	ChannelName    string
+	CallerIDName   string
+	CallerIDNumber string
+
+	// Hear the member hears the room, false when deaf
+	Hear bool
+	// Speak the room hears the member, false when muted
+	Speak     bool
+	Talking   bool
+	Floor     bool
+	Moderator bool
+
+	VolumeIn  int
+	VolumeOut int
+	Energy    int
+}
+
+// Event conference::maintenance event
+type Event struct {
+	Action         Action
+	Conference     string
+	ConferenceUUID string
+	// Size members in the room after the action
+	Size int
+	// Member the member acted on, nil for actions on the whole room
+	Member *Member
+
+	// OldFloor, NewFloor member ids of a floor-change, 0 for none
+	OldFloor int
+	NewFloor int
+	// File of the play-file actions
+	File string
+	// Path of the recording actions
+	Path string
+	// JobUUID, Result of a bgdial-result
+	JobUUID string
+	Result  string
+
+	Raw *esl.Event
+}
+
+// ParseEvent typed conference event, false when e is not a conference::maintenance event
+func ParseEvent(e *esl.Event) (Event, bool) {
+	if e.GetName() != "CUSTOM" || e.GetHeader("Event-Subclass") != EventSubclass {
+		return Event{}, false
+	}
+	event := Event{
+		Action:         Action(e.GetHeader("Action")),
+		Conference:     e.GetHeader("Conference-Name"),
+		ConferenceUUID: e.GetHeader("Conference-Unique-ID"),
+		Size:           atoi(e.GetHeader("Conference-Size")),
+		OldFloor:       atoi(e.GetHeader("Old-ID")),
+		NewFloor:       atoi(e.GetHeader("New-ID")),
+		File:           e.GetHeader("File"),
+		Path:           e.GetHeader("Path"),
+		JobUUID:        e.GetHeader("Job-UUID"),
+		Result:         e.GetHeader("Result"),
+		Raw:            e,
+	}
+	if id := atoi(e.GetHeader("Member-ID")); id > 0 {
+		event.Member = &Member{
+			ID:             id,
+			UUID:           e.GetHeader("Unique-ID"),
+			ChannelName:    e.GetHeader("Channel-Name"),
+			CallerIDName:   e.GetHeader("Caller-Caller-ID-Name"),
+			CallerIDNumber: e.GetHeader("Caller-Caller-ID-Number"),
+			Hear:           e.GetHeader("Hear") == "true",
+			Speak:          e.GetHeader("Speak") == "true",
+			Talking:        e.GetHeader("Talking") == "true",
+			Floor:          e.GetHeader("Floor") == "true",
+			Moderator:      e.GetHeader("Member-Type") == "moderator",
+			Energy:         atoi(e.GetHeader("Energy-Level")),
+		}
+	}
+	return event, true
+}
+
+// parseList `list` output, one member per line:
+// id;channel name;uuid;caller id name;caller id number;flags;volume in;volume out;energy
+func parseList(body string) ([]Member, error) {
+	var members []Member
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if line == "" || strings.HasPrefix(line, "+OK") {
+			continue
+		}
+		fields := strings.Split(line, ";")
+		if len(fields) < 9 {
+			return nil, fmt.Errorf("list: %q: %w", line, api.ErrUnexpectedResponse)
+		}
+		m := Member{
+			ID:             atoi(fields[0]),
+			ChannelName:    fields[1],
+			UUID:           fields[2],
+			CallerIDName:   fields[3],
+			CallerIDNumber: fields[4],
+			VolumeIn:       atoi(fields[6]),
+			VolumeOut:      atoi(fields[7]),
+			Energy:         atoi(fields[8]),
+		}
+		for _, flag := range strings.Split(fields[5], "|") {
+			switch flag {
+			case "hear":
+				m.Hear = true
+			case "speak":
+				m.Speak = true
+			case "talking":
+				m.Talking = true
+			case "floor":
+				m.Floor = true
+			case "moderator":
+				m.Moderator = true
+			}
+		}
+		members = append(members, m)
+	}
+	return members, nil
+}
+
+// atoi numeric header, 0 when missing or not a number
+func atoi(s string) int {
+	n, _ := strconv.Atoi(s)
+	return n
+}
@@ -0,0 +1,180 @@
+package conference
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/zhifeichen/esl/v2"
+)
+
+// Room snapshot of a room of the roster
+type Room struct {
+	Name   string
+	UUID   string
+	Locked bool
+	// Members ordered by id
+	Members []Member
+}
+
+// Member get a member by id
+func (r Room) Member(id int) (Member, bool) {
+	for _, m := range r.Members {
+		if m.ID == id {
+			return m, true
+		}
+	}
+	return Member{}, false
+}
+
+type room struct {
+	uuid    string
+	locked  bool
+	members map[int]*Member
+}
+
+func (r *room) snapshot(name string) Room {
+	s := Room{Name: name, UUID: r.uuid, Locked: r.locked, Members: make([]Member, 0, len(r.members))}
+	for _, m := range r.members {
+		s.Members = append(s.Members, *m)
+	}
+	sort.Slice(s.Members, func(i, j int) bool { return s.Members[i].ID < s.Members[j].ID })
+	return s
+}
+
+// Roster maintains the members of every room from conference::maintenance events, a room is removed
+// after conference-destroy
+type Roster struct {
+	sync.RWMutex
+	rooms    map[string]*room
+	onChange func(room Room, e Event)
+}
+
+// NewRoster create roster, feed it with HandleEvent or Watch
+func NewRoster() *Roster {
+	return &Roster{
+		rooms: make(map[string]*room),
+	}
+}
+
+// OnChange set callback called with a snapshot of the room after every event, including conference-destroy
+func (r *Roster) OnChange(fn func(room Room, e Event)) {
+	r.Lock()
+	defer r.Unlock()
+
+	r.onChange = fn
+}
+
+// HandleEvent update the roster, events other than conference::maintenance ones are ignored
+func (r *Roster) HandleEvent(e *esl.Event) {
+	event, ok := ParseEvent(e)
+	if !ok || event.Conference == "" {
+		return
+	}
+
+	r.Lock()
+	rm := r.room(event.Conference)
+	if event.ConferenceUUID != "" {
+		rm.uuid = event.ConferenceUUID
+	}
+	switch event.Action {
+	case ActionConferenceDestroy:
+		delete(r.rooms, event.Conference)
+	case ActionDelMember:
+		if event.Member != nil {
+			delete(rm.members, event.Member.ID)
+		}
+	case ActionLock:
+		rm.locked = true
+	case ActionUnlock:
+		rm.locked = false
+	case ActionFloorChange:
+		for id, m := range rm.members {
+			m.Floor = id == event.NewFloor
+		}
+	default:
+		if event.Member != nil {
+			m := *event.Member
+			if old, ok := rm.members[m.ID]; ok {
+				// volumes are only listed by `list`
+				m.VolumeIn, m.VolumeOut = old.VolumeIn, old.VolumeOut
+			}
+			rm.members[m.ID] = &m
+		}
+	}
+	snapshot := rm.snapshot(event.Conference)
+	onChange := r.onChange
+	r.Unlock()
+
+	if onChange != nil {
+		onChange(snapshot, event)
+	}
+}
+
+// room get or create room name. r must be locked.
+func (r *Roster) room(name string) *room {
+	rm, ok := r.rooms[name]
+	if !ok {
+		rm = &room{members: make(map[int]*Member)}
+		r.rooms[name] = rm
+	}
+	return rm
+}
+
+// Room get a snapshot of a room by name
+func (r *Roster) Room(name string) (Room, bool) {
+	r.RLock()
+	defer r.RUnlock()
+
+	rm, ok := r.rooms[name]
+	if !ok {
+		return Room{}, false
+	}
+	return rm.snapshot(name), true
+}
+
+// Rooms get snapshots of all rooms
+func (r *Roster) Rooms() []Room {
+	r.RLock()
+	defer r.RUnlock()
+
+	rooms := make([]Room, 0, len(r.rooms))
+	for name, rm := range r.rooms {
+		rooms = append(rooms, rm.snapshot(name))
+	}
+	return rooms
+}
+
+// Sync replace the members of room c with its `list`, for rooms started before the roster
+func (r *Roster) Sync(ctx context.Context, c *Conference) error {
+	members, err := c.List(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.Lock()
+	defer r.Unlock()
+
+	rm := r.room(c.Name)
+	rm.members = make(map[int]*Member, len(members))
+	for i := range members {
+		rm.members[members[i].ID] = &members[i]
+	}
+	return nil
+}
+
+// Watcher event source of a roster, *esl.Client or an inbound *esl.Connection
+type Watcher interface {
+	FilterMatch(m esl.Matcher, cb esl.EventHandler) *esl.Subscription
+	EnableEvent(ctx context.Context, events ...string) error
+}
+
+// Watch subscribe conference::maintenance events on conn and feed them to the roster
+func (r *Roster) Watch(ctx context.Context, conn Watcher) (*esl.Subscription, error) {
+	sub := conn.FilterMatch(esl.And(esl.EventName("CUSTOM"), esl.Subclass(EventSubclass)), r.HandleEvent)
+	if err := conn.EnableEvent(ctx, "CUSTOM", EventSubclass); err != nil {
+		sub.Unsubscribe()
+		return nil, err
+	}
+	return sub, nil
+}
@@ -0,0 +1,92 @@
+package conference
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+// maintenance conference::maintenance event of room 3000
+func maintenance(action string, kv ...string) *esltest.Event {
+	e := esltest.NewEvent("CUSTOM", "Event-Subclass", EventSubclass, "Action", action,
+		"Conference-Name", "3000", "Conference-Unique-ID", "room-1")
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.Set(kv[i], kv[i+1])
+	}
+	return e
+}
+
+func member(action, id, uuid string, kv ...string) *esltest.Event {
+	return maintenance(action, append([]string{"Member-ID", id, "Unique-ID", uuid,
+		"Hear", "true", "Speak", "true", "Talking", "false", "Floor", "false", "Member-Type", "member"}, kv...)...)
+}
+
+func TestRoster_Watch(t *testing.T) {
+	server, client := newTestClient(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	conn, err := server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roster := NewRoster()
+	if err := roster.Sync(ctx, New(client, "3000")); err != nil {
+		t.Fatal(err)
+	}
+	changes := make(chan Event, 16)
+	roster.OnChange(func(room Room, e Event) {
+		changes <- e
+	})
+	sub, err := roster.Watch(ctx, client)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Unsubscribe()
+	if _, err := conn.WaitCommand(ctx, "event plain CUSTOM "+EventSubclass); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.SendEvent(member("add-member", "3", "call-3", "Caller-Caller-ID-Name", "Carol"))
+	conn.SendEvent(member("mute-member", "2", "call-2", "Speak", "false"))
+	conn.SendEvent(maintenance("floor-change", "Old-ID", "1", "New-ID", "3"))
+	conn.SendEvent(member("del-member", "1", "call-1"))
+	conn.SendEvent(maintenance("lock"))
+	conn.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "sofia::register"))
+	for i := 0; i < 5; i++ {
+		select {
+		case <-changes:
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+	}
+
+	room, ok := roster.Room("3000")
+	if !ok {
+		t.Fatal("room 3000 missing")
+	}
+	if !room.Locked || room.UUID != "room-1" || len(room.Members) != 2 {
+		t.Fatalf("Room() = %+v", room)
+	}
+	if m, _ := room.Member(2); m.Speak || m.VolumeIn != 1 {
+		t.Errorf("member 2 = %+v, want muted with the listed volume", m)
+	}
+	if m, _ := room.Member(3); !m.Floor || m.CallerIDName != "Carol" {
+		t.Errorf("member 3 = %+v, want floor holder", m)
+	}
+
+	conn.SendEvent(maintenance("conference-destroy"))
+	select {
+	case e := <-changes:
+		if e.Action != ActionConferenceDestroy {
+			t.Errorf("Action = %s, want %s", e.Action, ActionConferenceDestroy)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+	if rooms := roster.Rooms(); len(rooms) != 0 {
+		t.Errorf("Rooms() after conference-destroy = %+v", rooms)
+	}
+}
@@ -9,20 +9,19 @@ package esl
 import (
 	"bufio"
 	"context"
-	"errors"
 	"net"
 	"net/textproto"
-	"net/url"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/log"
 )
 
 // Connection Main connection against ESL - Gotta add more description here
 type Connection struct {
 	conn           net.Conn
+	remoteAddr     net.Addr
 	reader         *bufio.Reader
 	header         *textproto.Reader
 	writeLock      sync.Mutex
@@ -31,9 +30,21 @@ type Connection struct {
 	responseChns   map[string]chan *RawResponse
 	responseChnMtx sync.RWMutex
 	filter         *filter
-	filterMtx      sync.RWMutex
+	subscriptions  *subscriptions
+	pending        pendingRequests
 	outbound       bool
+	eventFormat    string
 	closeOnce      sync.Once
+	// log of this connection, nil for the package logger
+	log *log.Logger
+}
+
+// logger logger of this connection, the one given to WithLogger or the package logger
+func (c *Connection) logger() *log.Logger {
+	if c.log != nil {
+		return c.log
+	}
+	return logger
 }
 
 // Dial - Will establish timedout dial against specified address. In this case, it will be freeswitch server
@@ -54,9 +65,8 @@ func newConnect(ctx context.Context, c net.Conn, outbound bool) *Connection {
 		runningContext: runningCtx,
 		stop:           stop,
 		outbound:       outbound,
+		eventFormat:    EventFormatPlain,
 		responseChns: map[string]chan *RawResponse{
-			TypeReply:       make(chan *RawResponse),
-			TypeAPIResponse: make(chan *RawResponse),
 			TypeEventPlain:  make(chan *RawResponse),
 			TypeEventJSON:   make(chan *RawResponse),
 			TypeEventXML:    make(chan *RawResponse),
@@ -64,18 +74,25 @@ func newConnect(ctx context.Context, c net.Conn, outbound bool) *Connection {
 			TypeDisconnect:  make(chan *RawResponse),
 		},
 	}
-	filter := &filter{
-		bgapi:  bgFilter{cb: make(map[string]EventHandler)},
-		event:  eventFilter{cb: make(map[string]EventHandler)},
-		header: headerFilter{cb: make([]*headerFilterItem, 0, 5)},
+	if c != nil {
+		instance.remoteAddr = c.RemoteAddr()
 	}
-	instance.filter = filter
+	instance.filter = newFilter()
+	instance.subscriptions = newSubscriptions()
 	return instance
 }
 
+// responseChn channel of a content type, nil once the connection is closed
+func (c *Connection) responseChn(contentType string) chan *RawResponse {
+	c.responseChnMtx.RLock()
+	defer c.responseChnMtx.RUnlock()
+
+	return c.responseChns[contentType]
+}
+
 // RemoteAddr return connection remote addr
 func (c *Connection) RemoteAddr() net.Addr {
-	return c.conn.RemoteAddr()
+	return c.remoteAddr
 }
 
 // LocalAddr return connection local addr
@@ -103,112 +120,84 @@ func (c *Connection) close() {
 	c.responseChnMtx.Lock()
 	defer c.responseChnMtx.Unlock()
 
-	logger.Info("close")
+	c.logger().Info("close")
+	// jobs of this connection will never complete
+	if c.filter != nil {
+		c.failJobs(ErrConnClosed)
+		c.failExecWaiters(ErrConnClosed)
+		c.closeDTMFReaders(ErrConnClosed)
+	}
+	c.pending.close()
+
 	for key, chn := range c.responseChns {
 		close(chn)
 		delete(c.responseChns, key)
-		logger.Infof("delete chn %s\n", key)
+		c.logger().Infof("delete chn %s\n", key)
 	}
 
-	logger.Info("close conn")
+	c.logger().Info("close conn")
 	if c.conn != nil {
+		// closing unblocks a pending write, then writers see the connection gone
 		c.conn.Close()
+		c.writeLock.Lock()
 		c.conn = nil
+		c.writeLock.Unlock()
 	}
-	logger.Info("closed")
+	c.logger().Info("closed")
 }
 
-// SendCommand send command to fs
+// SendCommand send command to fs. Commands are pipelined: several callers may wait for their replies at
+// once, each gets the reply of its own command. The reply of a caller gone with ctx is discarded.
 func (c *Connection) SendCommand(ctx context.Context, cmd command.Command, fn ...EventHandler) (*RawResponse, error) {
-	t1 := time.Now()
-	c.writeLock.Lock()
-	defer c.writeLock.Unlock()
-	esc := time.Since(t1).Milliseconds()
-	if esc > 500 {
-		logger.Errorf("esc > 500: %d\n", esc)
+	if v, ok := cmd.(command.Validator); ok {
+		if err := v.Validate(); err != nil {
+			return nil, err
+		}
 	}
-
-	sendString := cmd.BuildMessage()
-	logger.Debugf("send command: %s\n", sendString)
-	if c.conn == nil {
-		logger.Errorf("send command %s error: Connection closed", sendString)
-		return nil, ErrConnClosed
+	// register the background job callback before sending, the job may finish before the reply is read
+	var jobid string
+	if bgCmd, ok := cmd.(command.API); ok && bgCmd.Background && len(fn) > 0 {
+		if len(bgCmd.JobUUID) == 0 {
+			bgCmd.JobUUID = newUUID()
+			cmd = bgCmd
+		}
+		jobid = bgCmd.JobUUID
+		c.addJob(jobid, &bgJob{cb: fn[len(fn)-1]})
 	}
 
-	if deadline, ok := ctx.Deadline(); ok {
-		c.conn.SetWriteDeadline(deadline)
-	}
-	_, err := c.conn.Write([]byte(sendString + EndOfMessage))
-	if err != nil {
+	req := newRequest(cmd, jobid)
+	if err := c.writeRequest(ctx, req); err != nil {
+		c.removeJob(jobid)
 		return nil, err
 	}
 
-	background := false
-	var cb EventHandler
-	if bgCmd, ok := cmd.(command.API); ok && bgCmd.Background && len(fn) > 0 {
-		background = true
-		cb = fn[len(fn)-1]
-	}
-
-	c.responseChnMtx.RLock()
-	defer c.responseChnMtx.RUnlock()
 	select {
-	case response := <-c.responseChns[TypeReply]:
-		if response == nil {
+	case response, ok := <-req.reply:
+		if !ok {
+			c.removeJob(jobid)
 			return nil, ErrConnClosed
 		}
-		if response.IsOk() && background {
-			jobid := response.Headers.Get("Job-Uuid")
-			if len(jobid) > 0 {
-				c.filter.bgapi.Lock()
-				c.filter.bgapi.cb[jobid] = cb
-				c.filter.bgapi.Unlock()
-			}
-		}
-		return response, nil
-	case response := <-c.responseChns[TypeAPIResponse]:
-		if response == nil {
-			return nil, ErrConnClosed
-		}
-		if response.IsOk() && background {
-			jobid := response.Headers.Get("Job-Uuid")
-			if len(jobid) > 0 {
-				c.filter.bgapi.Lock()
-				c.filter.bgapi.cb[jobid] = cb
-				c.filter.bgapi.Unlock()
-			}
-		}
 		return response, nil
 	case <-ctx.Done():
+		c.abandon(req)
 		return nil, ctx.Err()
 	case <-c.runningContext.Done():
+		c.abandon(req)
 		return nil, c.runningContext.Err()
 	}
 }
 
+// receiveLoop read until the connection fails. Any read error is a lost connection: it is closed, which
+// closes the TypeDisconnect channel its watchers wait on.
 func (c *Connection) receiveLoop() {
 	for c.runningContext.Err() == nil {
 		err := c.doReceive()
 		if err != nil {
-			logger.Errorf("Error receiving message: %s; %#v\n", err.Error(), err)
-			if strings.Contains(err.Error(), "EOF") {
-				logger.Error("connection eof")
-				response := RawResponse{
-					Headers: make(textproto.MIMEHeader),
-				}
-				chnName := "text/disconnect-notice"
-				response.Headers.Add("Content-Type", chnName)
-				c.responseChnMtx.RLock()
-				defer c.responseChnMtx.RUnlock()
-				// c.responseChns[chnName] <- &response
-				select{
-				case c.responseChns[chnName] <- &response:
-					return
-				case <-c.runningContext.Done():
-					return
-				}
+			if c.runningContext.Err() == nil {
+				c.logger().Errorf("Error receiving message: %s; %#v\n", err.Error(), err)
 			}
-			break
+			c.Close()
+			return
 		}
 	}
 }
@@ -218,7 +207,13 @@ func (c *Connection) doReceive() error {
 	if err != nil {
 		return err
 	}
-	logger.Debugf("recv response: %#v\n", response)
+	c.logger().Debugf("recv response: %#v\n", response)
+
+	// replies are matched to the pending commands in order
+	if contentType := response.GetHeader("Content-Type"); contentType == TypeReply || contentType == TypeAPIResponse {
+		c.deliverReply(response)
+		return nil
+	}
 
 	c.responseChnMtx.RLock()
 	defer c.responseChnMtx.RUnlock()
@@ -241,20 +236,28 @@ func (c *Connection) doReceive() error {
 			return c.runningContext.Err()
 		case <-ctx.Done():
 			// Do not return an error since this is not fatal but logger since it could be a indication of problems
-			logger.Warnf("No one to handle response\nIs the connection overloaded or stopping?\n%v\n\n", response)
+			c.logger().Warnf("No one to handle response\nIs the connection overloaded or stopping?\n%v\n\n", response)
 		}
 	} else {
-		return errors.New("no response channel for Content-Type: " + response.GetHeader("Content-Type"))
+		// e.g. log/data once `log` is enabled without a reader, the stream is still in sync
+		c.logger().Debugf("skip response, no response channel for Content-Type: %s\n", response.GetHeader("Content-Type"))
 	}
 	return nil
 }
 
 func (c *Connection) eventLoop() {
+	// expired jobs are swept here, so expiry never races the delivery of their BACKGROUND_JOB
+	sweep := time.NewTicker(JobSweepInterval)
+	defer sweep.Stop()
 	for {
 		var event *Event
 		var err error
 		c.responseChnMtx.RLock()
 		select {
+		case now := <-sweep.C:
+			c.responseChnMtx.RUnlock()
+			c.expireJobs(now)
+			continue
 		case raw := <-c.responseChns[TypeEventPlain]:
 			if raw == nil {
 				// We only get nil here if the channel is closed
@@ -283,7 +286,7 @@ func (c *Connection) eventLoop() {
 		c.responseChnMtx.RUnlock()
 
 		if err != nil {
-			logger.Errorf("Error parsing event\n%s\n", err.Error())
+			c.logger().Errorf("Error parsing event\n%s\n", err.Error())
 			continue
 		}
 
@@ -292,94 +295,251 @@ func (c *Connection) eventLoop() {
 }
 
 func (c *Connection) handleEvent(event *Event) {
-	c.filterMtx.RLock()
-	defer c.filterMtx.RUnlock()
+	f := c.filter
+	// snapshot only, handlers may call TrackChannels or stop the dispatcher
+	f.mtx.RLock()
+	tracker, dispatch := f.tracker, f.dispatch
+	f.mtx.RUnlock()
 
 	eventName := event.GetName()
-	// first, call background job function
+	// keep channel state up to date before any callback sees the event
+	if tracker != nil {
+		tracker.HandleEvent(event)
+	}
+	// complete ExecuteWait calls and feed DTMF readers, the event is still dispatched below
+	c.notifyExecWaiters(event)
+	c.dispatchDTMF(event)
+
+	// the job is taken here, so expiry never races its delivery
+	var job *bgJob
 	if eventName == "BACKGROUND_JOB" {
-		uuid := event.GetHeader("Job-Uuid")
-		func() {
-			c.filter.bgapi.Lock()
-			defer c.filter.bgapi.Unlock()
-
-			if fn, ok := c.filter.bgapi.cb[uuid]; ok {
-				fn(event)
-				delete(c.filter.bgapi.cb, uuid)
-			}
-		}()
+		job = c.takeJob(event.GetHeader("Job-Uuid"))
+	}
+	// a Job completes on the event loop, the dispatcher may drop the event below
+	if job != nil && job.fail != nil {
+		job.cb(event)
+		job = nil
+	}
+
+	deliver := func() {
+		// first, call background job function
+		if job != nil {
+			job.cb(event)
+		}
+		for _, fn := range f.handlers(event) {
+			fn(event)
+		}
+		// the channel is gone, its filters can never match again
+		if eventName == EventChannelDestroy {
+			c.removeChannelFilters(event.ChannelUUID())
+		}
+	}
+	if dispatch != nil {
+		dispatch.dispatch(event.ChannelUUID(), deliver)
 		return
 	}
+	deliver()
+}
 
-	{ // call filter by event name
-		fn, ok := func() (EventHandler, bool) {
-			c.filter.event.RLock()
-			defer c.filter.event.RUnlock()
+// handlers snapshot of the handlers matching event, in dispatch order. Handlers run outside
+// the filter locks so they may subscribe or unsubscribe.
+func (f *filter) handlers(event *Event) []EventHandler {
+	var fns []EventHandler
+	f.event.RLock()
+	for _, h := range f.event.cb[event.GetName()] {
+		fns = append(fns, h.cb)
+	}
+	f.event.RUnlock()
 
-			fn, ok := c.filter.event.cb[eventName]
-			return fn, ok
-		}()
-		if ok {
-			fn(event)
-			return
+	f.header.RLock()
+	for _, hf := range f.header.cb {
+		if hf.match.Match(event) {
+			fns = append(fns, hf.cb)
 		}
 	}
+	f.header.RUnlock()
 
-	{ // call filter by header value
-		f, ok := func() (*headerFilterItem, bool) {
-			c.filter.header.RLock()
-			defer c.filter.header.RUnlock()
+	f.event.RLock()
+	for _, h := range f.event.cb[EventListenAll] {
+		fns = append(fns, h.cb)
+	}
+	f.event.RUnlock()
+	return fns
+}
 
-			for _, f := range c.filter.header.cb {
-				v := event.Headers.Values(f.header)
-				for _, vv := range v {
-					uv, _ := url.PathUnescape(vv)
-					if uv == f.value && f.cb != nil {
-						return f, true
-					}
-				}
+// FilterEvent add a handler of the events named name, EventListenAll for every event.
+//
+// Events fan out to every matching handler, each called once per event in this order: a SendCommand
+// bgapi callback for its BACKGROUND_JOB, the handlers of the event name, the FilterHeader handlers whose
+// header matches, then the ALL handlers. Handlers of the same key run in registration order.
+//
+// Without Dispatch workers the handlers run on the event loop goroutine and a slow handler delays all
+// others. With workers they run on the worker of the event Unique-ID: the events of one channel keep
+// their order, other channels are handled in parallel, and OverflowDropOldest or OverflowDropNewest
+// may drop events before their handlers run, see DispatchOptions.
+func (c *Connection) FilterEvent(name string, cb EventHandler) *Subscription {
+	f := c.filter
+	item := &eventHandlerItem{cb: cb}
+	f.event.Lock()
+	defer f.event.Unlock()
+
+	f.event.cb[name] = append(f.event.cb[name], item)
+	return &Subscription{remove: func() {
+		f.event.Lock()
+		defer f.event.Unlock()
+
+		items := f.event.cb[name]
+		for i, it := range items {
+			if it == item {
+				items = append(items[:i:i], items[i+1:]...)
+				break
 			}
-			return nil, false
-		}()
-		if ok {
-			f.cb(event)
-			return
 		}
-	}
+		if len(items) == 0 {
+			delete(f.event.cb, name)
+		} else {
+			f.event.cb[name] = items
+		}
+	}}
+}
 
-	{ // call ALL handler
-		c.filter.event.RLock()
-		if fn, ok := c.filter.event.cb[EventListenAll]; ok {
-			fn(event)
+// FilterHeader add a handler of the events having header set to value, see FilterEvent for the fan-out order
+func (c *Connection) FilterHeader(header, value string, cb EventHandler) *Subscription {
+	return c.filter.addHeader(&headerFilterItem{
+		header: textproto.CanonicalMIMEHeaderKey(header),
+		value:  value,
+		match:  HeaderEquals(header, value),
+		cb:     cb,
+	})
+}
+
+// addHeader add a header or matcher handler
+func (f *filter) addHeader(item *headerFilterItem) *Subscription {
+	f.header.Lock()
+	defer f.header.Unlock()
+
+	f.header.cb = append(f.header.cb, item)
+	return &Subscription{remove: func() {
+		f.header.Lock()
+		defer f.header.Unlock()
+
+		for i, it := range f.header.cb {
+			if it == item {
+				f.header.cb = append(f.header.cb[:i:i], f.header.cb[i+1:]...)
+				return
+			}
 		}
-		c.filter.event.RUnlock()
-	}
+	}}
 }
 
-// FilterEvent add event filter callback
-func (c *Connection) FilterEvent(name string, cb EventHandler) {
+// RemoveFilterEvent remove all handlers of the events named name
+func (c *Connection) RemoveFilterEvent(name string) {
 	c.filter.event.Lock()
 	defer c.filter.event.Unlock()
 
-	c.filter.event.cb[name] = cb
+	delete(c.filter.event.cb, name)
 }
 
-// FilterHeader filter by header name and value, add callback
-func (c *Connection) FilterHeader(header, value string, cb EventHandler) {
-	c.filter.header.Lock()
-	defer c.filter.header.Unlock()
+// RemoveFilterHeader remove all handlers of header and value, then delete the matching server side filter
+// when one was set with `filter`
+func (c *Connection) RemoveFilterHeader(ctx context.Context, header, value string) error {
+	c.filter.removeHeader(header, value)
+	f, ok := c.subscriptions.filter(header, value)
+	if !ok {
+		return nil
+	}
+	f.Delete = true
+	response, err := c.SendCommand(ctx, f)
+	if err != nil {
+		return err
+	}
+	if !response.IsOk() {
+		return replyError(response)
+	}
+	return nil
+}
 
-	memeHeader := textproto.CanonicalMIMEHeaderKey(header)
-	for _, hf := range c.filter.header.cb {
-		if hf.header == memeHeader && hf.value == value {
-			hf.cb = cb
-			return
+// FilterChannel handle the events of channel uuid and add the server side filter `filter Unique-ID uuid`.
+// Both are removed after CHANNEL_DESTROY of the channel or by Unsubscribe.
+func (c *Connection) FilterChannel(ctx context.Context, uuid string, cb EventHandler) (*Subscription, error) {
+	return filterChannel(ctx, func() *Connection { return c }, uuid, cb)
+}
+
+// filterChannel FilterChannel on the connection returned by conn, asked again on Unsubscribe
+func filterChannel(ctx context.Context, conn func() *Connection, uuid string, cb EventHandler) (*Subscription, error) {
+	c := conn()
+	response, err := c.SendCommand(ctx, command.Filter{EventHeader: "Unique-ID", FilterValue: uuid})
+	if err != nil {
+		return nil, err
+	}
+	if !response.IsOk() {
+		return nil, replyError(response)
+	}
+	sub := c.FilterHeader("Unique-ID", uuid, cb)
+	remove := sub.remove
+	sub.remove = func() {
+		remove()
+		if c := conn(); !c.filter.hasHeader("Unique-ID", uuid) {
+			c.deleteServerFilter(uuid)
 		}
 	}
-	f := headerFilterItem{
-		header: memeHeader,
-		value:  value,
-		cb:     cb,
+	return sub, nil
+}
+
+// removeChannelFilters remove the handlers of channel uuid and its server side filter
+func (c *Connection) removeChannelFilters(uuid string) {
+	if len(uuid) == 0 {
+		return
+	}
+	c.filter.removeHeader("Unique-ID", uuid)
+	c.deleteServerFilter(uuid)
+}
+
+// deleteServerFilter delete the server side filter of channel uuid in background, the event loop
+// must not wait for replies
+func (c *Connection) deleteServerFilter(uuid string) {
+	cmd, ok := c.subscriptions.filter("Unique-ID", uuid)
+	if !ok {
+		return
+	}
+	cmd.Delete = true
+	go func() {
+		ctx, cancel := context.WithTimeout(c.runningContext, 5*time.Second)
+		defer cancel()
+
+		if response, err := c.SendCommand(ctx, cmd); err != nil {
+			c.logger().Warnf("%s: %s\n", cmd.BuildMessage(), err.Error())
+		} else if !response.IsOk() {
+			c.logger().Warnf("%s: %s\n", cmd.BuildMessage(), response.GetReply())
+		}
+	}()
+}
+
+// hasHeader some handler of header and value is left
+func (f *filter) hasHeader(header, value string) bool {
+	header = textproto.CanonicalMIMEHeaderKey(header)
+	f.header.RLock()
+	defer f.header.RUnlock()
+
+	for _, hf := range f.header.cb {
+		if hf.header == header && hf.value == value {
+			return true
+		}
+	}
+	return false
+}
+
+// removeHeader remove all handlers of header and value
+func (f *filter) removeHeader(header, value string) {
+	header = textproto.CanonicalMIMEHeaderKey(header)
+	f.header.Lock()
+	defer f.header.Unlock()
+
+	items := make([]*headerFilterItem, 0, len(f.header.cb))
+	for _, hf := range f.header.cb {
+		if hf.header != header || hf.value != value {
+			items = append(items, hf)
+		}
 	}
-	c.filter.header.cb = append(c.filter.header.cb, &f)
+	f.header.cb = items
 }
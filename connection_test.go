@@ -8,89 +8,51 @@ package esl
 
 import (
 	"context"
+	"net"
 	"net/textproto"
-	"os"
 	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/zhifeichen/esl/v2/command"
-	"github.com/zhifeichen/log"
+	"github.com/zhifeichen/esl/v2/esltest"
 )
 
 var (
-	testCnt = 200
-	rCnt    = 200
-	cCnt    = 32
+	testCnt = 20
+	rCnt    = 20
+	cCnt    = 4
 )
 
-func TestConnection_SendCommand(t *testing.T) {
-	// type args struct {
-	// 	ctx context.Context
-	// 	cmd command.Command
-	// 	fn  []EventHandler
-	// }
-	// tests := []struct {
-	// 	name    string
-	// 	c       *Connection
-	// 	args    args
-	// 	want    *RawResponse
-	// 	wantErr bool
-	// }{
-	// 	// TODO: Add test cases.
-	// }
-	// for _, tt := range tests {
-	// 	t.Run(tt.name, func(t *testing.T) {
-	// 		got, err := tt.c.SendCommand(tt.args.ctx, tt.args.cmd, tt.args.fn...)
-	// 		if (err != nil) != tt.wantErr {
-	// 			t.Errorf("Connection.SendCommand() error = %v, wantErr %v", err, tt.wantErr)
-	// 			return
-	// 		}
-	// 		if !reflect.DeepEqual(got, tt.want) {
-	// 			t.Errorf("Connection.SendCommand() = %v, want %v", got, tt.want)
-	// 		}
-	// 	})
-	// }
-
-	t1 := time.Now()
-	defer func() {
-		esc := time.Since(t1).Milliseconds()
-		t.Errorf("escap %d ms\n", esc)
-	}()
+// newTestServer start a fake FreeSWITCH answering `status`
+func newTestServer(t testing.TB) *esltest.Server {
+	server, err := esltest.NewServer(esltest.DefaultPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	server.HandleAPI("status", func(args string) string {
+		return "UP 0 years, 0 days, 0 hours, 12 minutes\nFreeSWITCH (Version 1.10.7 64bit) is ready\n"
+	})
+	t.Cleanup(server.Close)
+	return server
+}
 
-	log.Init(log.NewOptions(
-		log.Level("error"),
-		log.Writers(os.Stdout),
-	))
-	host := "192.168.135.134"
-	// host := "172.21.24.80"
-	port := uint16(18021)
-	// port := uint16(8021)
-	auth := "ClueCon"
-	// clients := make([]*Client, cCnt)
-	// var err error
-	// for _, c := range clients {
-	// 	c, err = NewClient(host, port, auth, 3)
-	// 	if err != nil {
-	// 		t.Error(err)
-	// 	}
-	// 	err = c.Start("plain", "BACKGROUND_JOB")
-	// 	if err != nil {
-	// 		t.Error(err)
-	// 	}
-	// 	go c.sendLoop()
-	// }
-	client, err := NewClient(host, port, auth, 3, cCnt)
+// newTestClient start a client against the fake server
+func newTestClient(t testing.TB, server *esltest.Server, format string, sendConnCnt int) *Client {
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, sendConnCnt)
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	err = client.Start("plain", "BACKGROUND_JOB")
+	err = client.Start(format, "BACKGROUND_JOB")
 	if err != nil {
-		t.Error(err)
+		t.Fatal(err)
 	}
-	// go client.sendLoop()
+	t.Cleanup(client.Stop)
+	return client
+}
 
+func newSendEvent(host string) *command.SendEvent {
 	cmd := command.SendEvent{
 		Name:    "SEND_MESSAGE",
 		Headers: make(textproto.MIMEHeader),
@@ -99,49 +61,29 @@ func TestConnection_SendCommand(t *testing.T) {
 	cmd.Headers.Add("User", "19900001111202104fu")
 	cmd.Headers.Add("Host", host)
 	cmd.Headers.Add("profile", "internal")
+	return &cmd
+}
+
+func TestConnection_SendCommand(t *testing.T) {
+	t1 := time.Now()
+	defer func() {
+		esc := time.Since(t1).Milliseconds()
+		t.Logf("escap %d ms\n", esc)
+	}()
+
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", cCnt)
+
 	t2 := time.Now()
-	// _, err := client.SendCommand(context.Background(), &cmd)
-	// if err != nil {
-	// 	t.Error(err)
-	// }
 	wt := sync.WaitGroup{}
 	wt.Add(1)
-	client.SendCommand2(context.Background(), &cmd, func(e *Event) {
+	client.SendCommand2(context.Background(), newSendEvent(server.Host()), func(e *Event) {
 		esc2 := time.Since(t2).Milliseconds()
-		t.Errorf("esc: %d\n", esc2)
+		t.Logf("esc: %d\n", esc2)
 		wt.Done()
 	})
 	wt.Wait()
-	// return
-
-	// client2, err := NewClient(host, port, auth, 3)
-	// if err != nil {
-	// 	t.Error(err)
-	// }
-	// err = client2.Start("plain", "BACKGROUND_JOB")
-	// if err != nil {
-	// 	t.Error(err)
-	// }
-	// go client2.sendLoop()
-
-	// client3, err := NewClient(host, port, auth, 3)
-	// if err != nil {
-	// 	t.Error(err)
-	// }
-	// err = client3.Start("plain", "BACKGROUND_JOB")
-	// if err != nil {
-	// 	t.Error(err)
-	// }
-	// go client3.sendLoop()
-
-	// cmd := command.SendEvent{
-	// 	Name:    "SEND_MESSAGE",
-	// 	Headers: make(textproto.MIMEHeader),
-	// 	Body:    "test",
-	// }
-	// cmd.Headers.Add("User", "19900001111202104fu")
-	// cmd.Headers.Add("Host", host)
-	// cmd.Headers.Add("profile", "internal")
+
 	var tt, max, cnt int64 = 0, 0, 0
 	wg := sync.WaitGroup{}
 	wg.Add(rCnt * testCnt)
@@ -149,82 +91,37 @@ func TestConnection_SendCommand(t *testing.T) {
 	for j := 0; j < rCnt; j++ {
 		go func() {
 			for i := 0; i < testCnt; i++ {
-				cmd := command.SendEvent{
-					Name:    "SEND_MESSAGE",
-					Headers: make(textproto.MIMEHeader),
-					Body:    "test",
-				}
-				cmd.Headers.Add("User", "19900001111202104fu")
-				cmd.Headers.Add("Host", host)
-				cmd.Headers.Add("profile", "internal")
 				t2 := time.Now()
-				// _, err := client.SendCommand(context.Background(), &cmd)
-				// if err != nil {
-				// 	t.Error(err)
-				// }
-				client.SendCommand2(context.Background(), &cmd, func(e *Event) {
+				client.SendCommand2(context.Background(), newSendEvent(server.Host()), func(e *Event) {
 					esc2 := time.Since(t2).Milliseconds()
-					// tt += esc2
 					atomic.AddInt64(&tt, esc2)
-					if esc2 > max {
-						max = esc2
+					if esc2 > atomic.LoadInt64(&max) {
+						atomic.StoreInt64(&max, esc2)
 					}
-					// cnt++
 					atomic.AddInt64(&cnt, 1)
 					wg.Done()
 				})
-				// esc2 := time.Since(t2).Microseconds()
-				// tt += esc2
-				// if esc2 > max {
-				// 	max = esc2
-				// }
 			}
 		}()
 	}
 	wg.Wait()
-	t.Errorf("tt: %d; evg esc: %d; max: %d, cnt: %d, chn len: %d, esc: %d\n", tt, tt/int64(cnt), max, cnt, len(client.sendParamChn), time.Since(t3).Milliseconds())
+	t.Logf("tt: %d; evg esc: %d; max: %d, cnt: %d, esc: %d\n", tt, tt/cnt, max, cnt, time.Since(t3).Milliseconds())
 }
 
 func TestConnection_SendCommand2(t *testing.T) {
-	t1 := time.Now()
-	defer func() {
-		esc := time.Since(t1).Milliseconds()
-		t.Errorf("escap %d ms\n", esc)
-	}()
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", cCnt)
 
-	log.Init(log.NewOptions(
-		log.Level("error"),
-		// log.Writers(rl.Stdout()),
-	))
-	// host := "192.168.135.134"
-	host := "172.21.24.80"
-	// port := uint16(18021)
-	port := uint16(8021)
-	auth := "ClueCon"
-	client, err := NewClient(host, port, auth, 3, cCnt)
-	if err != nil {
-		t.Error(err)
-	}
-	err = client.Start("plain", "BACKGROUND_JOB")
-	if err != nil {
-		t.Error(err)
-	}
-	cmd := command.SendEvent{
-		Name:    "SEND_MESSAGE",
-		Headers: make(textproto.MIMEHeader),
-		Body:    "test",
-	}
-	cmd.Headers.Add("User", "19900001111202104fu")
-	cmd.Headers.Add("Host", host)
-	cmd.Headers.Add("profile", "internal")
+	cmd := newSendEvent(server.Host())
 	var tt, max, cnt int64 = 0, 0, 0
-	wg := sync.WaitGroup{}
-	wg.Add(testCnt * rCnt)
 	for i := 0; i < testCnt*rCnt; i++ {
 		t2 := time.Now()
-		_, err := client.SendCommand(context.Background(), &cmd)
+		resp, err := client.SendCommand(context.Background(), cmd)
 		if err != nil {
-			t.Error(err)
+			t.Fatal(err)
+		}
+		if !resp.IsOk() {
+			t.Fatalf("send event reply: %s", resp.GetReply())
 		}
 		esc2 := time.Since(t2).Milliseconds()
 		tt += esc2
@@ -232,117 +129,44 @@ func TestConnection_SendCommand2(t *testing.T) {
 			max = esc2
 		}
 		cnt++
-		wg.Done()
 	}
-	wg.Wait()
-	t.Errorf("tt: %d; evg esc: %d; max: %d, cnt: %d\n", tt, tt/int64(cnt), max, cnt)
+	t.Logf("tt: %d; evg esc: %d; max: %d, cnt: %d\n", tt, tt/cnt, max, cnt)
 }
 
 func TestConnection_SendCommand3(t *testing.T) {
-	t1 := time.Now()
-	defer func() {
-		esc := time.Since(t1).Milliseconds()
-		t.Errorf("escap %d ms\n", esc)
-	}()
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", cCnt)
 
-	log.Init(log.NewOptions(
-		log.Level("error"),
-		// log.Writers(rl.Stdout()),
-	))
-	// host := "192.168.135.134"
-	// host := "172.21.24.117"
-	host := "172.21.24.80"
-	// port := uint16(18021)
-	port := uint16(8021)
-	auth := "ClueCon"
-	client, err := NewClient(host, port, auth, 3, cCnt)
-	if err != nil {
-		t.Error(err)
-	}
-	err = client.Start("plain", "BACKGROUND_JOB")
-	if err != nil {
-		t.Error(err)
-	}
-	// cmd := command.SendEvent{
-	// 	Name:    "SEND_MESSAGE",
-	// 	Headers: make(textproto.MIMEHeader),
-	// 	Body:    "test",
-	// }
-	// cmd.Headers.Add("User", "19900001111202104fu")
-	// cmd.Headers.Add("Host", host)
-	// cmd.Headers.Add("profile", "internal")
-	var tt, max, cnt int64 = 0, 0, 0
+	var failed int64
 	wg := sync.WaitGroup{}
-	wg.Add(rCnt * testCnt)
+	wg.Add(rCnt)
 	for j := 0; j < rCnt; j++ {
 		go func() {
+			defer wg.Done()
 			for i := 0; i < testCnt; i++ {
-				cmd := command.SendEvent{
-					Name:    "SEND_MESSAGE",
-					Headers: make(textproto.MIMEHeader),
-					Body:    "test",
-				}
-				cmd.Headers.Add("User", "19900001111202104fu")
-				cmd.Headers.Add("Host", host)
-				cmd.Headers.Add("profile", "internal")
-				t2 := time.Now()
-				_, err := client.SendCommand(context.Background(), &cmd)
-				if err != nil {
-					t.Error(err)
+				resp, err := client.SendCommand(context.Background(), newSendEvent(server.Host()))
+				if err != nil || !resp.IsOk() {
+					atomic.AddInt64(&failed, 1)
 				}
-				esc2 := time.Since(t2).Milliseconds()
-				tt += esc2
-				if esc2 > max {
-					max = esc2
-				}
-				cnt++
-
-				wg.Done()
 			}
 		}()
 	}
 	wg.Wait()
-	t.Errorf("tt: %d; evg esc: %d; max: %d, cnt: %d\n", tt, tt/int64(cnt), max, cnt)
+	if failed > 0 {
+		t.Errorf("%d commands failed", failed)
+	}
 }
 
 func TestConnection_SendCommand4(t *testing.T) {
-	t1 := time.Now()
-	defer func() {
-		esc := time.Since(t1).Milliseconds()
-		t.Errorf("escap %d ms\n", esc)
-	}()
-
-	log.Init(log.NewOptions(
-		log.Level("error"),
-		// log.Writers(rl.Stdout()),
-	))
-	host := "192.168.135.134"
-	// host := "172.21.24.117"
-	// host := "172.21.24.80"
-	port := uint16(18021)
-	// port := uint16(8021)
-	auth := "ClueCon"
-	client, err := NewClient(host, port, auth, 3, 1)
-	if err != nil {
-		t.Error(err)
-	}
-	err = client.Start("plain", "BACKGROUND_JOB")
-	if err != nil {
-		t.Error(err)
-	}
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", 1)
 
 	wg := sync.WaitGroup{}
 	wg.Add(2)
-	cmd := command.SendEvent{
-		Name:    "SEND_MESSAGE",
-		Headers: make(textproto.MIMEHeader),
-		Body:    "test",
-	}
-	cmd.Headers.Add("User", "19900001111202104fu")
-	cmd.Headers.Add("Host", host)
-	cmd.Headers.Add("profile", "internal")
-	client.SendCommand2(context.Background(), &cmd, func(e *Event) {
-		t.Errorf("%#v\n", e)
+	client.SendCommand2(context.Background(), newSendEvent(server.Host()), func(e *Event) {
+		if e.GetHeader("Reply-Text")[:3] != "+OK" {
+			t.Errorf("unexpected reply: %#v\n", e)
+		}
 		wg.Done()
 	})
 
@@ -352,68 +176,351 @@ func TestConnection_SendCommand4(t *testing.T) {
 		Background: true,
 	}
 	client.SendCommand2(context.Background(), cmd2, func(e *Event) {
-		t.Errorf("%#v\n", e)
+		if e.GetName() != "BACKGROUND_JOB" || len(e.Body) == 0 {
+			t.Errorf("unexpected background job: %#v\n", e)
+		}
 		wg.Done()
 	})
 	wg.Wait()
 }
 
-func Benchmark_SendCommand(b *testing.B) {
-	log.Init(log.NewOptions(
-		log.Level("fatal"),
-		// log.Writers(rl.Stdout()),
-	))
-	// host := "192.168.135.134"
-	host := "172.21.24.80"
-	// port := uint16(18021)
-	port := uint16(8021)
-	auth := "ClueCon"
-	client, err := NewClient(host, port, auth, 3, cCnt)
+func TestClient_API(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", 0)
+
+	resp, err := client.SendCommand(context.Background(), command.API{Command: "status"})
 	if err != nil {
-		b.Error(err)
+		t.Fatal(err)
 	}
-	err = client.Start("plain", "BACKGROUND_JOB")
+	if string(resp.Body[:2]) != "UP" {
+		t.Errorf("api status = %q", resp.Body)
+	}
+	resp, err = client.SendCommand(context.Background(), command.API{Command: "no_such_api"})
 	if err != nil {
-		b.Error(err)
+		t.Fatal(err)
 	}
-	cmd := command.SendEvent{
-		Name:    "SEND_MESSAGE",
-		Headers: make(textproto.MIMEHeader),
-		Body:    "test",
+	if resp.IsOk() {
+		t.Errorf("api no_such_api = %q, want -ERR", resp.Body)
 	}
-	for i := 0; i < b.N; i++ {
-		_, err := client.SendCommand(context.Background(), &cmd)
+}
+
+func TestClient_Events(t *testing.T) {
+	for _, format := range []string{"plain", "json", "xml"} {
+		t.Run(format, func(t *testing.T) {
+			server := newTestServer(t)
+			client := newTestClient(t, server, format, 0)
+			conn, err := server.WaitConn(context.Background())
+			if err != nil {
+				t.Fatal(err)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			if _, err := conn.WaitCommand(ctx, "event "+format); err != nil {
+				t.Fatal(err)
+			}
+
+			got := make(chan *Event, 1)
+			client.FilterEvent("CHANNEL_CREATE", func(e *Event) {
+				got <- e
+			})
+			err = conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE",
+				"Unique-ID", "3b7a5bf6-1f3c-4d6b-9b43-5f4b1f8a1e2a",
+				"Caller-Caller-ID-Number", "1000 #1",
+			).WithBody("body"))
+			if err != nil {
+				t.Fatal(err)
+			}
+			select {
+			case e := <-got:
+				if e.ChannelUUID() != "3b7a5bf6-1f3c-4d6b-9b43-5f4b1f8a1e2a" {
+					t.Errorf("Unique-ID = %q", e.ChannelUUID())
+				}
+				if e.GetHeader("Caller-Caller-ID-Number") != "1000 #1" {
+					t.Errorf("Caller-Caller-ID-Number = %q", e.GetHeader("Caller-Caller-ID-Number"))
+				}
+				if string(e.Body) != "body" {
+					t.Errorf("Body = %q", e.Body)
+				}
+			case <-ctx.Done():
+				t.Fatal("event not received")
+			}
+		})
+	}
+}
+
+func TestClient_EventFormat(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Start("yaml", "ALL"); err != ErrUnsupportedEventFormat {
+		t.Fatalf("Start(yaml) error = %v, want %v", err, ErrUnsupportedEventFormat)
+	}
+	if err := client.Start(EventFormatJSON, "ALL"); err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	// main connection and both send connections subscribe json
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for i := 0; i < 3; i++ {
+		conn, err := server.WaitConn(ctx)
 		if err != nil {
-			b.Error(err)
+			t.Fatal(err)
+		}
+		if _, err := conn.WaitCommand(ctx, "event json"); err != nil {
+			t.Fatal(err)
 		}
 	}
+
+	if err := client.SetEventFormat("yaml"); err != ErrUnsupportedEventFormat {
+		t.Errorf("SetEventFormat(yaml) error = %v, want %v", err, ErrUnsupportedEventFormat)
+	}
+	if err := client.SetEventFormat(EventFormatXML); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.EnableEvent(ctx, "CHANNEL_CREATE"); err != nil {
+		t.Fatal(err)
+	}
+	if client.EventFormat() != EventFormatXML {
+		t.Errorf("EventFormat() = %q", client.EventFormat())
+	}
 }
 
-func Benchmark_SendCommand2(b *testing.B) {
-	log.Init(log.NewOptions(
-		log.Level("fatal"),
-		// log.Writers(rl.Stdout()),
-	))
-	host := "192.168.135.134"
-	// host := "172.21.24.80"
-	port := uint16(18021)
-	// port := uint16(8021)
-	auth := "ClueCon"
-	client, err := NewClient(host, port, auth, 3, cCnt)
+func TestClient_EventFanOut(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, EventFormatPlain, 0)
+	conn, err := server.WaitConn(context.Background())
 	if err != nil {
-		b.Error(err)
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := conn.WaitCommand(ctx, "event plain"); err != nil {
+		t.Fatal(err)
 	}
-	err = client.Start("plain", "BACKGROUND_JOB")
+
+	calls := make(chan string, 16)
+	handler := func(name string) EventHandler {
+		return func(e *Event) {
+			calls <- name + " " + e.GetName()
+		}
+	}
+	client.FilterEvent(EventListenAll, handler("all"))
+	first := client.FilterEvent("CHANNEL_CREATE", handler("first"))
+	client.FilterEvent("CHANNEL_CREATE", handler("second"))
+	client.FilterHeader("Answer-State", "ringing", handler("header"))
+	var self *Subscription
+	self = client.FilterEvent("CHANNEL_CREATE", func(e *Event) {
+		self.Unsubscribe()
+		calls <- "once " + e.GetName()
+	})
+
+	expect := func(want ...string) {
+		t.Helper()
+		for _, w := range want {
+			select {
+			case got := <-calls:
+				if got != w {
+					t.Errorf("handler call = %q, want %q", got, w)
+				}
+			case <-ctx.Done():
+				t.Fatalf("handler call %q missing", w)
+			}
+		}
+	}
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE", "Answer-State", "ringing"))
+	expect("first CHANNEL_CREATE", "second CHANNEL_CREATE", "once CHANNEL_CREATE",
+		"header CHANNEL_CREATE", "all CHANNEL_CREATE")
+
+	first.Unsubscribe()
+	first.Unsubscribe()
+	conn.SendEvent(esltest.NewEvent("CHANNEL_CREATE"))
+	conn.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Answer-State", "ringing"))
+	expect("second CHANNEL_CREATE", "all CHANNEL_CREATE", "header CHANNEL_ANSWER", "all CHANNEL_ANSWER")
+	select {
+	case got := <-calls:
+		t.Errorf("unexpected handler call %q", got)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestClient_RemoveFilters(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, EventFormatPlain, 0)
+	conn, err := server.WaitConn(context.Background())
 	if err != nil {
-		b.Error(err)
+		t.Fatal(err)
 	}
-	cmd := command.SendEvent{
-		Name:    "SEND_MESSAGE",
-		Headers: make(textproto.MIMEHeader),
-		Body:    "test",
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := conn.WaitCommand(ctx, "event plain"); err != nil {
+		t.Fatal(err)
 	}
+
+	calls := make(chan string, 16)
+	handler := func(name string) EventHandler {
+		return func(e *Event) {
+			calls <- name + " " + e.GetName()
+		}
+	}
+	client.FilterEvent("CHANNEL_ANSWER", handler("answer"))
+	client.FilterEvent("CHANNEL_ANSWER", handler("answer"))
+	client.FilterHeader("Answer-State", "ringing", handler("ringing"))
+	if _, err := client.FilterChannel(ctx, "call-1", handler("call-1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WaitCommand(ctx, "filter Unique-ID call-1"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendCommand(ctx, command.Filter{EventHeader: "Answer-State", FilterValue: "ringing"}); err != nil {
+		t.Fatal(err)
+	}
+	client.FilterEvent(EventListenAll, handler("all"))
+
+	client.RemoveFilterEvent("CHANNEL_ANSWER")
+	if err := client.RemoveFilterHeader(ctx, "answer-state", "ringing"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WaitCommand(ctx, "filter delete Answer-State ringing"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Answer-State", "ringing", "Unique-ID", "call-1"))
+	conn.SendEvent(esltest.NewEvent(EventChannelDestroy, "Unique-ID", "call-1"))
+	if _, err := conn.WaitCommand(ctx, "filter delete Unique-ID call-1"); err != nil {
+		t.Fatal(err)
+	}
+	conn.SendEvent(esltest.NewEvent("CHANNEL_HANGUP", "Unique-ID", "call-1"))
+	want := []string{"call-1 CHANNEL_ANSWER", "all CHANNEL_ANSWER", "call-1 CHANNEL_DESTROY", "all CHANNEL_DESTROY", "all CHANNEL_HANGUP"}
+	for _, w := range want {
+		select {
+		case got := <-calls:
+			if got != w {
+				t.Errorf("handler call = %q, want %q", got, w)
+			}
+		case <-ctx.Done():
+			t.Fatalf("handler call %q missing", w)
+		}
+	}
+	if client.filter.hasHeader("Unique-ID", "call-1") {
+		t.Error("channel filter not removed after CHANNEL_DESTROY")
+	}
+}
+
+func TestClient_Reconnect(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// make sure the subscription is done before disconnecting
+	if _, err := conn.WaitCommand(ctx, "event "); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendCommand(ctx, command.API{Command: "status"}); err != nil {
+		t.Fatal(err)
+	}
+
+	conn.Disconnect(false)
+	conn, err = server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WaitCommand(ctx, "event "); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestListenAndServe(t *testing.T) {
+	server := newTestServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	type result struct {
+		data  *RawResponse
+		event *Event
+	}
+	results := make(chan result, 1)
+	done := make(chan struct{})
+	go ListenAndServe(addr, func(ctx context.Context, conn *Connection) {
+		resp, err := conn.SendCommand(ctx, command.Connect{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		events := make(chan *Event, 1)
+		conn.FilterEvent("CHANNEL_ANSWER", func(e *Event) {
+			events <- e
+		})
+		conn.SetEventFormat(EventFormatJSON)
+		conn.EnableEvent(ctx)
+		results <- result{resp, <-events}
+		<-ctx.Done()
+		close(done)
+	})
+
+	var fs *esltest.Conn
+	for i := 0; i < 50; i++ {
+		fs, err = server.DialOutbound(addr, esltest.NewEvent("CHANNEL_DATA",
+			"Unique-ID", "e3b1b8f6-5a1c-4c3f-a2c4-8b1bc7f3c0d1",
+			"Channel-Destination-Number", "9999",
+		))
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if _, err := fs.WaitCommand(ctx, "myevents json"); err != nil {
+		t.Fatal(err)
+	}
+	fs.SendEvent(esltest.NewEvent("CHANNEL_ANSWER", "Unique-ID", "e3b1b8f6-5a1c-4c3f-a2c4-8b1bc7f3c0d1"))
+
+	select {
+	case r := <-results:
+		if r.data.ChannelUUID() != "e3b1b8f6-5a1c-4c3f-a2c4-8b1bc7f3c0d1" {
+			t.Errorf("channel data Unique-ID = %q", r.data.ChannelUUID())
+		}
+		if r.data.GetHeader("Channel-Destination-Number") != "9999" {
+			t.Errorf("channel data Channel-Destination-Number = %q", r.data.GetHeader("Channel-Destination-Number"))
+		}
+		if r.event.GetName() != "CHANNEL_ANSWER" {
+			t.Errorf("event = %q", r.event.GetName())
+		}
+	case <-ctx.Done():
+		t.Fatal("outbound handler timeout")
+	}
+
+	fs.Disconnect(false)
+	select {
+	case <-done:
+	case <-ctx.Done():
+		t.Fatal("handler context not done after disconnect")
+	}
+}
+
+func Benchmark_SendCommand(b *testing.B) {
+	server := newTestServer(b)
+	client := newTestClient(b, server, "plain", cCnt)
+	cmd := newSendEvent(server.Host())
+	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		_, err := client.SendCommand(context.Background(), &cmd)
+		_, err := client.SendCommand(context.Background(), cmd)
 		if err != nil {
 			b.Error(err)
 		}
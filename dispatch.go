@@ -0,0 +1,186 @@
+package esl
+
+import (
+	"hash/fnv"
+	"sync"
+	"sync/atomic"
+)
+
+// OverflowPolicy what the event loop does when the queue of a dispatch worker is full
+type OverflowPolicy int
+
+// overflow policies
+const (
+	// OverflowBlock wait for room, the event loop and the replies behind it stall meanwhile
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropOldest drop the oldest queued event of the worker to make room
+	OverflowDropOldest
+	// OverflowDropNewest drop the incoming event
+	OverflowDropNewest
+)
+
+func (p OverflowPolicy) String() string {
+	switch p {
+	case OverflowBlock:
+		return "block"
+	case OverflowDropOldest:
+		return "drop-oldest"
+	case OverflowDropNewest:
+		return "drop-newest"
+	}
+	return "unknown"
+}
+
+// DispatchOptions asynchronous dispatch of event handlers. With Workers zero handlers run on the event loop.
+//
+// Events are routed to a worker by Unique-ID, so the handlers see the events of one channel in order while
+// channels are handled in parallel. Events without Unique-ID share one worker and keep their order too.
+// Channel state tracking, ExecuteWait, DTMF readers and the Jobs of StartBgAPI and BgAPI are still updated
+// on the event loop, so a dropped event never leaves them waiting. A bgapi callback given to SendCommand runs
+// with the handlers and is lost with a dropped BACKGROUND_JOB.
+type DispatchOptions struct {
+	// Workers number of worker goroutines
+	Workers int
+	// QueueSize queued events per worker, default 256
+	QueueSize int
+	// Overflow policy when a worker queue is full
+	Overflow OverflowPolicy
+}
+
+// DispatchStats counters of the dispatcher
+type DispatchStats struct {
+	// Queued events waiting in the worker queues
+	Queued int
+	// Delivered events whose handlers have run
+	Delivered uint64
+	// Blocked events the event loop waited to queue, with OverflowBlock
+	Blocked uint64
+	// Dropped events dropped by OverflowDropOldest or OverflowDropNewest, or queued while stopping
+	Dropped uint64
+}
+
+type dispatcher struct {
+	// counters first, 64 bit atomics need 64 bit alignment on 32 bit platforms
+	delivered uint64
+	blocked   uint64
+	dropped   uint64
+	overflow  OverflowPolicy
+	queues    []chan func()
+	done      chan struct{}
+	// mtx held for reading while queuing, so the queues are not closed under a sender
+	mtx       sync.RWMutex
+	closed    bool
+	closeOnce sync.Once
+}
+
+func newDispatcher(o DispatchOptions) *dispatcher {
+	size := o.QueueSize
+	if size <= 0 {
+		size = 256
+	}
+	d := &dispatcher{
+		overflow: o.Overflow,
+		queues:   make([]chan func(), o.Workers),
+		done:     make(chan struct{}),
+	}
+	for i := range d.queues {
+		d.queues[i] = make(chan func(), size)
+		go d.work(d.queues[i])
+	}
+	return d
+}
+
+func (d *dispatcher) work(queue chan func()) {
+	for fn := range queue {
+		fn()
+		atomic.AddUint64(&d.delivered, 1)
+	}
+}
+
+// queueOf queue of the worker handling key
+func (d *dispatcher) queueOf(key string) chan func() {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return d.queues[h.Sum32()%uint32(len(d.queues))]
+}
+
+// dispatch queue fn on the worker of key
+func (d *dispatcher) dispatch(key string, fn func()) {
+	queue := d.queueOf(key)
+
+	d.mtx.RLock()
+	defer d.mtx.RUnlock()
+
+	if d.closed {
+		atomic.AddUint64(&d.dropped, 1)
+		return
+	}
+	select {
+	case queue <- fn:
+		return
+	default:
+	}
+	switch d.overflow {
+	case OverflowDropNewest:
+		atomic.AddUint64(&d.dropped, 1)
+	case OverflowDropOldest:
+		for {
+			select {
+			case <-queue:
+				atomic.AddUint64(&d.dropped, 1)
+			default:
+			}
+			select {
+			case queue <- fn:
+				return
+			default:
+			}
+		}
+	default:
+		atomic.AddUint64(&d.blocked, 1)
+		select {
+		case queue <- fn:
+		case <-d.done:
+			atomic.AddUint64(&d.dropped, 1)
+		}
+	}
+}
+
+// close stop accepting events, the workers exit once their queue is drained
+func (d *dispatcher) close() {
+	d.closeOnce.Do(func() {
+		close(d.done)
+
+		d.mtx.Lock()
+		defer d.mtx.Unlock()
+
+		d.closed = true
+		for _, queue := range d.queues {
+			close(queue)
+		}
+	})
+}
+
+func (d *dispatcher) stats() DispatchStats {
+	queued := 0
+	for _, queue := range d.queues {
+		queued += len(queue)
+	}
+	return DispatchStats{
+		Queued:    queued,
+		Delivered: atomic.LoadUint64(&d.delivered),
+		Blocked:   atomic.LoadUint64(&d.blocked),
+		Dropped:   atomic.LoadUint64(&d.dropped),
+	}
+}
+
+// DispatchStats counters of the dispatcher, zero when handlers run on the event loop
+func (c *Connection) DispatchStats() DispatchStats {
+	c.filter.mtx.RLock()
+	defer c.filter.mtx.RUnlock()
+
+	if c.filter.dispatch == nil {
+		return DispatchStats{}
+	}
+	return c.filter.dispatch.stats()
+}
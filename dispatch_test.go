@@ -0,0 +1,206 @@
+package esl
+
+import (
+	"context"
+	"reflect"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestDispatcher_Overflow(t *testing.T) {
+	tests := []struct {
+		overflow OverflowPolicy
+		want     []int
+		stats    DispatchStats
+	}{
+		{OverflowDropNewest, []int{0, 1}, DispatchStats{Delivered: 4, Dropped: 3}},
+		{OverflowDropOldest, []int{3, 4}, DispatchStats{Delivered: 4, Dropped: 3}},
+		{OverflowBlock, []int{0, 1, 2, 3, 4}, DispatchStats{Delivered: 7, Blocked: 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overflow.String(), func(t *testing.T) {
+			d := newDispatcher(DispatchOptions{Workers: 1, QueueSize: 2, Overflow: tt.overflow})
+			defer d.close()
+
+			release := make(chan struct{})
+			got := make(chan int, 8)
+			d.dispatch("call-1", func() { <-release })
+			// wait until the worker holds the blocking job
+			for d.stats().Queued > 0 {
+				time.Sleep(time.Millisecond)
+			}
+			pushed := make(chan struct{})
+			go func() {
+				for i := 0; i < 5; i++ {
+					i := i
+					d.dispatch("call-1", func() { got <- i })
+				}
+				close(pushed)
+			}()
+			if tt.overflow == OverflowBlock {
+				select {
+				case <-pushed:
+					t.Fatal("dispatch did not block on a full queue")
+				case <-time.After(20 * time.Millisecond):
+				}
+			} else {
+				<-pushed
+			}
+			close(release)
+			<-pushed
+
+			var order []int
+			for range tt.want {
+				select {
+				case i := <-got:
+					order = append(order, i)
+				case <-time.After(time.Second):
+					t.Fatalf("delivered %v, want %v", order, tt.want)
+				}
+			}
+			if !reflect.DeepEqual(order, tt.want) {
+				t.Errorf("delivered %v, want %v", order, tt.want)
+			}
+			// the barrier runs after every job queued before it
+			barrier := make(chan struct{})
+			d.dispatch("call-1", func() { close(barrier) })
+			<-barrier
+			// Delivered is counted once the job returned
+			for i := 0; i < 100 && d.stats().Delivered < tt.stats.Delivered; i++ {
+				time.Sleep(time.Millisecond)
+			}
+			stats := d.stats()
+			// how many of the last jobs had to wait depends on the worker
+			if stats.Blocked > tt.stats.Blocked {
+				stats.Blocked = tt.stats.Blocked
+			}
+			if stats != tt.stats {
+				t.Errorf("stats = %+v, want %+v", stats, tt.stats)
+			}
+		})
+	}
+}
+
+func TestClient_DispatchDropJob(t *testing.T) {
+	server := newTestServer(t)
+	client, err := Dial(context.Background(), server.Addr(),
+		WithPassword(esltest.DefaultPassword),
+		WithDispatch(DispatchOptions{Workers: 1, QueueSize: 1, Overflow: OverflowDropNewest}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := conn.WaitCommand(ctx, "event plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	// block the only worker and fill its queue
+	started, release := make(chan struct{}, 1), make(chan struct{})
+	defer close(release)
+	client.FilterEvent("CHANNEL_PROGRESS", func(e *Event) {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+		<-release
+	})
+	conn.SendEvent(esltest.NewEvent("CHANNEL_PROGRESS", "Unique-ID", "call-1"))
+	select {
+	case <-started:
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+	conn.SendEvent(esltest.NewEvent("CHANNEL_PROGRESS", "Unique-ID", "call-1"))
+
+	// the BACKGROUND_JOB is dropped by the dispatcher, the job completes anyway
+	result, err := client.BgAPI(ctx, "status", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !result.OK {
+		t.Errorf("BgAPI() = %+v", result)
+	}
+	if stats := client.DispatchStats(); stats.Dropped == 0 {
+		t.Errorf("stats = %+v, want dropped events", stats)
+	}
+	if n := client.JobStats().Pending; n != 0 {
+		t.Errorf("%d jobs left", n)
+	}
+}
+
+func TestClient_Dispatch(t *testing.T) {
+	server := newTestServer(t)
+	client, err := Dial(context.Background(), server.Addr(),
+		WithPassword(esltest.DefaultPassword),
+		WithDispatch(DispatchOptions{Workers: 4, QueueSize: 16}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if _, err := conn.WaitCommand(ctx, "event plain"); err != nil {
+		t.Fatal(err)
+	}
+
+	// find two channels routed to different workers
+	slow, fast := "call-0", ""
+	for i := 1; len(fast) == 0; i++ {
+		uuid := "call-" + strconv.Itoa(i)
+		if client.filter.dispatch.queueOf(uuid) != client.filter.dispatch.queueOf(slow) {
+			fast = uuid
+		}
+	}
+
+	release := make(chan struct{})
+	got := make(chan string, 16)
+	client.FilterEvent("CHANNEL_PROGRESS", func(e *Event) {
+		if e.ChannelUUID() == slow {
+			<-release
+		}
+		got <- e.ChannelUUID() + " " + e.GetHeader("Seq")
+	})
+	for i := 0; i < 3; i++ {
+		conn.SendEvent(esltest.NewEvent("CHANNEL_PROGRESS", "Unique-ID", slow, "Seq", strconv.Itoa(i)))
+		conn.SendEvent(esltest.NewEvent("CHANNEL_PROGRESS", "Unique-ID", fast, "Seq", strconv.Itoa(i)))
+	}
+	next := func() string {
+		select {
+		case s := <-got:
+			return s
+		case <-ctx.Done():
+			t.Fatal(ctx.Err())
+		}
+		return ""
+	}
+	// the slow handler does not hold back the other channel
+	for i := 0; i < 3; i++ {
+		if s, want := next(), fast+" "+strconv.Itoa(i); s != want {
+			t.Errorf("event = %q, want %q", s, want)
+		}
+	}
+	close(release)
+	for i := 0; i < 3; i++ {
+		if s, want := next(), slow+" "+strconv.Itoa(i); s != want {
+			t.Errorf("event = %q, want %q", s, want)
+		}
+	}
+	if stats := client.DispatchStats(); stats.Dropped != 0 {
+		t.Errorf("stats = %+v", stats)
+	}
+}
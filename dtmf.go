@@ -0,0 +1,258 @@
+package esl
+
+import (
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventDTMF dtmf digit received on a channel
+const EventDTMF = "DTMF"
+
+// DTMFEvents events a DTMFReader needs on an inbound connection, outbound `myevents` includes them
+var DTMFEvents = []string{
+	EventDTMF,
+	EventChannelHangupComplete,
+	EventChannelDestroy,
+}
+
+// DTMF one digit
+type DTMF struct {
+	Digit string
+	// Duration DTMF-Duration, FreeSWITCH counts it in 8kHz samples
+	Duration time.Duration
+	// Source DTMF-Source, e.g. RTP, INBAND_AUDIO, APP
+	Source string
+	Event  *Event
+}
+
+func newDTMF(e *Event) DTMF {
+	samples, _ := strconv.Atoi(e.GetHeader("DTMF-Duration"))
+	return DTMF{
+		Digit:    e.GetHeader("DTMF-Digit"),
+		Duration: time.Duration(samples) * time.Second / 8000,
+		Source:   e.GetHeader("DTMF-Source"),
+		Event:    e,
+	}
+}
+
+// DTMFReader buffered stream of the digits of one channel. It ends when the channel is gone,
+// the connection is closed or Close is called.
+type DTMFReader struct {
+	conn    *Connection
+	uuid    string
+	chn     chan DTMF
+	err     error
+	dropped uint64
+}
+
+type dtmfFilter struct {
+	sync.Mutex
+	readers map[string][]*DTMFReader
+}
+
+// DTMF start reading the digits of channel uuid, buffer digits are kept while nobody reads,
+// later ones are dropped. The connection must receive DTMFEvents of the channel.
+func (c *Connection) DTMF(uuid string, buffer int) *DTMFReader {
+	if buffer <= 0 {
+		buffer = 16
+	}
+	r := &DTMFReader{
+		conn: c,
+		uuid: uuid,
+		chn:  make(chan DTMF, buffer),
+	}
+	c.filter.dtmf.Lock()
+	defer c.filter.dtmf.Unlock()
+
+	c.filter.dtmf.readers[uuid] = append(c.filter.dtmf.readers[uuid], r)
+	return r
+}
+
+// C digits, closed when the reader ends
+func (r *DTMFReader) C() <-chan DTMF {
+	return r.chn
+}
+
+// Err why the reader ended: ErrChannelHangup, ErrConnClosed, or nil after Close
+func (r *DTMFReader) Err() error {
+	r.conn.filter.dtmf.Lock()
+	defer r.conn.filter.dtmf.Unlock()
+
+	return r.err
+}
+
+// Dropped digits dropped because the buffer was full
+func (r *DTMFReader) Dropped() uint64 {
+	return atomic.LoadUint64(&r.dropped)
+}
+
+// Read wait for the next digit
+func (r *DTMFReader) Read(ctx context.Context) (DTMF, error) {
+	select {
+	case d, ok := <-r.chn:
+		if !ok {
+			return DTMF{}, r.closedErr()
+		}
+		return d, nil
+	case <-ctx.Done():
+		return DTMF{}, ctx.Err()
+	}
+}
+
+// closedErr error of reads after the reader ended, io.EOF after Close
+func (r *DTMFReader) closedErr() error {
+	if err := r.Err(); err != nil {
+		return err
+	}
+	return io.EOF
+}
+
+// Close stop reading
+func (r *DTMFReader) Close() {
+	r.conn.filter.dtmf.Lock()
+	defer r.conn.filter.dtmf.Unlock()
+
+	readers := r.conn.filter.dtmf.readers[r.uuid]
+	for i, reader := range readers {
+		if reader == r {
+			r.conn.removeDTMFReader(r.uuid, i, nil)
+			return
+		}
+	}
+}
+
+// removeDTMFReader end reader i of uuid, filter.dtmf must be locked
+func (c *Connection) removeDTMFReader(uuid string, i int, err error) {
+	readers := c.filter.dtmf.readers[uuid]
+	r := readers[i]
+	readers = append(readers[:i], readers[i+1:]...)
+	if len(readers) == 0 {
+		delete(c.filter.dtmf.readers, uuid)
+	} else {
+		c.filter.dtmf.readers[uuid] = readers
+	}
+	r.err = err
+	close(r.chn)
+}
+
+// CollectOptions end conditions of Collect, at least one should be set
+type CollectOptions struct {
+	// MaxDigits stop after that many digits
+	MaxDigits int
+	// Terminators digits ending the input, not included in the result
+	Terminators string
+	// FirstDigitTimeout wait for the first digit, InterDigitTimeout when zero
+	FirstDigitTimeout time.Duration
+	// InterDigitTimeout wait between digits
+	InterDigitTimeout time.Duration
+}
+
+// CollectResult digits collected by Collect
+type CollectResult struct {
+	Digits string
+	// Terminator terminator that ended the input, empty otherwise
+	Terminator string
+	// TimedOut input ended by a timeout
+	TimedOut bool
+}
+
+// Collect read digits until a terminator, MaxDigits or a timeout
+func (r *DTMFReader) Collect(ctx context.Context, o CollectOptions) (CollectResult, error) {
+	var result CollectResult
+	var digits strings.Builder
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+	var timeoutC <-chan time.Time
+	reset := func(d time.Duration) {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timeoutC = nil
+		if d > 0 {
+			timer.Reset(d)
+			timeoutC = timer.C
+		}
+	}
+	if o.FirstDigitTimeout > 0 {
+		reset(o.FirstDigitTimeout)
+	} else {
+		reset(o.InterDigitTimeout)
+	}
+
+	for {
+		select {
+		case d, ok := <-r.chn:
+			if !ok {
+				result.Digits = digits.String()
+				return result, r.closedErr()
+			}
+			if len(d.Digit) > 0 && strings.Contains(o.Terminators, d.Digit) {
+				result.Digits, result.Terminator = digits.String(), d.Digit
+				return result, nil
+			}
+			digits.WriteString(d.Digit)
+			if o.MaxDigits > 0 && digits.Len() >= o.MaxDigits {
+				result.Digits = digits.String()
+				return result, nil
+			}
+			reset(o.InterDigitTimeout)
+		case <-timeoutC:
+			result.Digits, result.TimedOut = digits.String(), true
+			return result, nil
+		case <-ctx.Done():
+			result.Digits = digits.String()
+			return result, ctx.Err()
+		}
+	}
+}
+
+// dispatchDTMF feed DTMF events to the readers of the channel, end them when the channel is gone
+func (c *Connection) dispatchDTMF(e *Event) {
+	name := e.GetName()
+	if name != EventDTMF && name != EventChannelHangupComplete && name != EventChannelDestroy {
+		return
+	}
+	uuid := e.ChannelUUID()
+	c.filter.dtmf.Lock()
+	defer c.filter.dtmf.Unlock()
+
+	if name != EventDTMF {
+		for len(c.filter.dtmf.readers[uuid]) > 0 {
+			c.removeDTMFReader(uuid, 0, ErrChannelHangup)
+		}
+		return
+	}
+	d := newDTMF(e)
+	for _, r := range c.filter.dtmf.readers[uuid] {
+		select {
+		case r.chn <- d:
+		default:
+			atomic.AddUint64(&r.dropped, 1)
+		}
+	}
+}
+
+// closeDTMFReaders end all readers with err
+func (c *Connection) closeDTMFReaders(err error) {
+	c.filter.dtmf.Lock()
+	defer c.filter.dtmf.Unlock()
+
+	for uuid := range c.filter.dtmf.readers {
+		for len(c.filter.dtmf.readers[uuid]) > 0 {
+			c.removeDTMFReader(uuid, 0, err)
+		}
+	}
+}
+
+// DTMF start reading the digits of the call
+func (s *OutboundSession) DTMF(buffer int) *DTMFReader {
+	return s.Connection.DTMF(s.UUID(), buffer)
+}
@@ -0,0 +1,110 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestConnection_DTMF(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "json", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.EnableEvent(ctx, DTMFEvents...); err != nil {
+		t.Fatal(err)
+	}
+	digits := func(uuid string, digits ...string) {
+		for _, d := range digits {
+			conn.SendEvent(esltest.NewEvent(EventDTMF, "Unique-ID", uuid,
+				"DTMF-Digit", d, "DTMF-Duration", "1600", "DTMF-Source", "RTP"))
+		}
+	}
+
+	r := client.DTMF("call-1", 0)
+	other := client.DTMF("call-2", 0)
+	digits("call-1", "1")
+	d, err := r.Read(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Digit != "1" || d.Duration != 200*time.Millisecond || d.Source != "RTP" {
+		t.Errorf("Read() = %+v", d)
+	}
+
+	digits("call-1", "2", "3", "#", "4", "5", "6", "7")
+	result, err := r.Collect(ctx, CollectOptions{Terminators: "#*", InterDigitTimeout: time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (CollectResult{Digits: "23", Terminator: "#"}) {
+		t.Errorf("Collect() = %+v", result)
+	}
+	result, err = r.Collect(ctx, CollectOptions{MaxDigits: 3, Terminators: "#"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (CollectResult{Digits: "456"}) {
+		t.Errorf("Collect() = %+v", result)
+	}
+	result, err = r.Collect(ctx, CollectOptions{MaxDigits: 4, InterDigitTimeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (CollectResult{Digits: "7", TimedOut: true}) {
+		t.Errorf("Collect() = %+v", result)
+	}
+	select {
+	case d := <-other.C():
+		t.Errorf("call-2 got digit %+v", d)
+	default:
+	}
+
+	conn.SendEvent(esltest.NewEvent(EventChannelHangupComplete, "Unique-ID", "call-1"))
+	if _, err := r.Read(ctx); !errors.Is(err, ErrChannelHangup) {
+		t.Errorf("Read() error = %v, want %v", err, ErrChannelHangup)
+	}
+	other.Close()
+	if _, err := other.Read(ctx); err != io.EOF {
+		t.Errorf("Read() error = %v, want %v", err, io.EOF)
+	}
+}
+
+func TestConnection_DTMFDropped(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.EnableEvent(ctx, DTMFEvents...); err != nil {
+		t.Fatal(err)
+	}
+	r := client.DTMF("call-1", 2)
+	for _, d := range []string{"1", "2", "3", "4"} {
+		conn.SendEvent(esltest.NewEvent(EventDTMF, "Unique-ID", "call-1", "DTMF-Digit", d))
+	}
+	conn.SendEvent(esltest.NewEvent(EventChannelDestroy, "Unique-ID", "call-1"))
+	for r.Err() == nil && ctx.Err() == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	result, err := r.Collect(ctx, CollectOptions{})
+	if !errors.Is(err, ErrChannelHangup) {
+		t.Errorf("Collect() error = %v, want %v", err, ErrChannelHangup)
+	}
+	if result.Digits != "12" || r.Dropped() != 2 {
+		t.Errorf("Collect() = %+v, dropped %d", result, r.Dropped())
+	}
+}
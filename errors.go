@@ -31,6 +31,15 @@ var (
 	ErrConnClosed              = errors.New("Connection closed")
 	ErrResponseChn             = errors.New("no response channels")
 	ErrNotImplement            = errors.New("not implement")
+	ErrUnsupportedEventFormat  = errors.New("unsupported event format, must be plain, json or xml")
+	ErrJobFailed               = errors.New("background job failed")
+	ErrJobExpired              = errors.New("background job expired")
+	ErrJobPending              = errors.New("background job pending")
+	ErrServerClosed            = errors.New("outbound server closed")
+	ErrCommandFailed           = errors.New("command failed")
+	ErrChannelHangup           = errors.New("channel hung up")
+	ErrMatcherNotExpressible   = errors.New("matcher cannot be expressed as server side filter")
+	ErrPoolClosed              = errors.New("pool closed")
 )
 
 type eslError struct {
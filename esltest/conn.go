@@ -0,0 +1,427 @@
+package esltest
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Command command received from the esl client
+type Command struct {
+	// Name first word of the command, e.g. "api", "sendmsg"
+	Name string
+	// Args rest of the command line
+	Args    string
+	Headers textproto.MIMEHeader
+	Body    string
+}
+
+// String the command line
+func (c Command) String() string {
+	if len(c.Args) > 0 {
+		return c.Name + " " + c.Args
+	}
+	return c.Name
+}
+
+// Conn one event socket connection of the fake server
+type Conn struct {
+	server      *Server
+	conn        net.Conn
+	reader      *bufio.Reader
+	header      *textproto.Reader
+	writeLock   sync.Mutex
+	outbound    bool
+	channelData *Event
+
+	mtx      sync.Mutex
+	authed   bool
+	format   string
+	commands []*Command
+	notify   chan struct{}
+	closed   chan struct{}
+	once     sync.Once
+}
+
+func (s *Server) newConn(c net.Conn, outbound bool) *Conn {
+	reader := bufio.NewReader(c)
+	return &Conn{
+		server:   s,
+		conn:     c,
+		reader:   reader,
+		header:   textproto.NewReader(reader),
+		outbound: outbound,
+		notify:   make(chan struct{}),
+		closed:   make(chan struct{}),
+	}
+}
+
+// Outbound is this connection dialed to an outbound server
+func (c *Conn) Outbound() bool {
+	return c.outbound
+}
+
+// Authenticated has the client authenticated
+func (c *Conn) Authenticated() bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.authed
+}
+
+// EventsEnabled has the client enabled events with `event` or `myevents`
+func (c *Conn) EventsEnabled() bool {
+	return len(c.EventFormat()) > 0
+}
+
+// EventFormat event format subscribed by the client, empty if not subscribed
+func (c *Conn) EventFormat() string {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	return c.format
+}
+
+// Commands all commands received so far
+func (c *Conn) Commands() []*Command {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	cmds := make([]*Command, len(c.commands))
+	copy(cmds, c.commands)
+	return cmds
+}
+
+// WaitCommand wait until a command whose command line starts with prefix is received
+func (c *Conn) WaitCommand(ctx context.Context, prefix string) (*Command, error) {
+	for {
+		c.mtx.Lock()
+		notify := c.notify
+		for _, cmd := range c.commands {
+			if strings.HasPrefix(cmd.String(), prefix) {
+				c.mtx.Unlock()
+				return cmd, nil
+			}
+		}
+		c.mtx.Unlock()
+
+		select {
+		case <-notify:
+		case <-c.closed:
+			return nil, io.EOF
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// Done closed when the connection is closed
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Close close the connection without notice
+func (c *Conn) Close() {
+	c.once.Do(func() {
+		close(c.closed)
+		c.conn.Close()
+	})
+}
+
+func (c *Conn) serve() {
+	defer c.Close()
+	for {
+		cmd, err := c.readCommand()
+		if err != nil {
+			return
+		}
+		c.mtx.Lock()
+		c.commands = append(c.commands, cmd)
+		close(c.notify)
+		c.notify = make(chan struct{})
+		c.mtx.Unlock()
+
+		if fn, ok := c.server.handler(cmd.Name); ok && fn(c, cmd) {
+			continue
+		}
+		c.handle(cmd)
+	}
+}
+
+func (c *Conn) readCommand() (*Command, error) {
+	var line string
+	var err error
+	// skip blank lines between commands
+	for len(line) == 0 {
+		line, err = c.header.ReadLine()
+		if err != nil {
+			return nil, err
+		}
+	}
+	headers, err := c.header.ReadMIMEHeader()
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+	cmd := &Command{
+		Headers: headers,
+	}
+	parts := strings.SplitN(line, " ", 2)
+	cmd.Name = parts[0]
+	if len(parts) > 1 {
+		cmd.Args = parts[1]
+	}
+	if contentLength := headers.Get("Content-Length"); len(contentLength) > 0 {
+		length, err := strconv.Atoi(contentLength)
+		if err != nil {
+			return nil, err
+		}
+		body := make([]byte, length)
+		if _, err := io.ReadFull(c.reader, body); err != nil {
+			return nil, err
+		}
+		cmd.Body = string(body)
+	}
+	return cmd, nil
+}
+
+func (c *Conn) handle(cmd *Command) {
+	switch cmd.Name {
+	case "auth":
+		if cmd.Args != c.server.Password {
+			c.Reply("-ERR invalid")
+			c.Disconnect(false)
+			return
+		}
+		c.setAuthed()
+		c.Reply("+OK accepted")
+	case "userauth":
+		parts := strings.SplitN(cmd.Args, ":", 2)
+		if len(parts) != 2 || c.server.Users[parts[0]] != parts[1] {
+			c.Reply("-ERR invalid")
+			c.Disconnect(false)
+			return
+		}
+		c.setAuthed()
+		c.Reply("+OK accepted")
+	case "api":
+		name, args := splitAPI(cmd.Args)
+		c.APIResponse(c.callAPI(name, args))
+	case "bgapi":
+		name, args := splitAPI(cmd.Args)
+		jobid := cmd.Headers.Get("Job-UUID")
+		if len(jobid) == 0 {
+			jobid = NewUUID()
+		}
+		c.Reply("+OK Job-UUID: "+jobid, "Job-UUID", jobid)
+		go func() {
+			time.Sleep(c.server.JobDelay)
+			e := NewEvent("BACKGROUND_JOB",
+				"Job-UUID", jobid,
+				"Job-Command", name,
+			).WithBody(c.callAPI(name, args))
+			if len(args) > 0 {
+				e.Set("Job-Command-Arg", args)
+			}
+			c.SendEvent(e)
+		}()
+	case "event", "myevents":
+		format := "plain"
+		if fields := strings.Fields(cmd.Args); len(fields) > 0 {
+			format = fields[0]
+		}
+		c.mtx.Lock()
+		c.format = format
+		c.mtx.Unlock()
+		c.Reply("+OK event listener enabled " + format)
+	case "noevents":
+		c.mtx.Lock()
+		c.format = ""
+		c.mtx.Unlock()
+		c.Reply("+OK no longer listening for events")
+	case "connect":
+		if !c.outbound {
+			c.Reply("-ERR command not found")
+			return
+		}
+		c.reply(TypeReply, c.channelData.headerLines()+"Reply-Text: +OK\n", "")
+	case "sendevent":
+		c.Reply("+OK " + NewUUID())
+	case "exit":
+		c.Reply("+OK bye")
+		c.Disconnect(false)
+	case "sendmsg":
+		c.Reply("+OK")
+		c.execute(cmd)
+	case "nixevent", "filter", "linger", "nolinger", "divert_events", "log", "nolog", "resume":
+		c.Reply("+OK")
+	default:
+		c.Reply("-ERR command not found")
+	}
+}
+
+// execute complete an application execution handled by Server.HandleApp
+func (c *Conn) execute(cmd *Command) {
+	if !strings.EqualFold(cmd.Headers.Get("Call-Command"), "execute") {
+		return
+	}
+	app := cmd.Headers.Get("Execute-App-Name")
+	fn, ok := c.server.app(app)
+	if !ok {
+		return
+	}
+	uuid := strings.TrimSpace(cmd.Args)
+	if len(uuid) == 0 && c.channelData != nil {
+		uuid = c.channelData.Headers.Get("Unique-ID")
+	}
+	args := cmd.Headers.Get("Execute-App-Arg")
+	if len(cmd.Body) > 0 {
+		args = cmd.Body
+	}
+	appUUID := cmd.Headers.Get("Event-UUID")
+	go func() {
+		time.Sleep(c.server.JobDelay)
+		response, kv := fn(uuid, args)
+		e := NewEvent("CHANNEL_EXECUTE_COMPLETE",
+			"Unique-ID", uuid,
+			"Application", app,
+			"Application-Data", args,
+			"Application-Response", response,
+			"Application-UUID", appUUID,
+		)
+		for i := 0; i+1 < len(kv); i += 2 {
+			e.Set(kv[i], kv[i+1])
+		}
+		c.SendEvent(e)
+	}()
+}
+
+func (c *Conn) setAuthed() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.authed = true
+}
+
+func (c *Conn) callAPI(name, args string) string {
+	fn, ok := c.server.api(name)
+	if !ok {
+		return fmt.Sprintf("-ERR %s Command not found!\n", name)
+	}
+	return fn(args)
+}
+
+func splitAPI(line string) (string, string) {
+	parts := strings.SplitN(strings.TrimSpace(line), " ", 2)
+	if len(parts) > 1 {
+		return parts[0], strings.TrimSpace(parts[1])
+	}
+	return parts[0], ""
+}
+
+// response content type
+const (
+	TypeEventPlain  = `text/event-plain`
+	TypeEventJSON   = `text/event-json`
+	TypeEventXML    = `text/event-xml`
+	TypeReply       = `command/reply`
+	TypeAPIResponse = `api/response`
+	TypeAuthRequest = `auth/request`
+	TypeDisconnect  = `text/disconnect-notice`
+	TypeLog         = `log/data`
+)
+
+// reply write a frame, extra is extra url encoded header lines
+func (c *Conn) reply(contentType, extra, body string) error {
+	var builder strings.Builder
+	if len(body) > 0 {
+		builder.WriteString("Content-Length: " + strconv.Itoa(len(body)) + "\n")
+	}
+	builder.WriteString("Content-Type: " + contentType + "\n")
+	builder.WriteString(extra)
+	builder.WriteString("\n")
+	builder.WriteString(body)
+
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := io.WriteString(c.conn, builder.String())
+	return err
+}
+
+// Reply write a command/reply with Reply-Text and extra header key/value pairs
+func (c *Conn) Reply(text string, kv ...string) error {
+	e := &Event{Headers: make(textproto.MIMEHeader)}
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.Headers.Add(kv[i], kv[i+1])
+	}
+	return c.reply(TypeReply, "Reply-Text: "+text+"\n"+e.headerLines(), "")
+}
+
+// APIResponse write an api/response
+func (c *Conn) APIResponse(body string) error {
+	return c.reply(TypeAPIResponse, "", body)
+}
+
+func (c *Conn) sendAuthRequest() error {
+	return c.reply(TypeAuthRequest, "", "")
+}
+
+// SendEvent push event in the format subscribed by the client (plain by default)
+func (c *Conn) SendEvent(e *Event) error {
+	switch c.EventFormat() {
+	case "json":
+		return c.SendJSONEvent(e)
+	case "xml":
+		return c.SendXMLEvent(e)
+	default:
+		return c.SendPlainEvent(e)
+	}
+}
+
+// SendPlainEvent push event as text/event-plain
+func (c *Conn) SendPlainEvent(e *Event) error {
+	return c.reply(TypeEventPlain, "", e.plain())
+}
+
+// SendJSONEvent push event as text/event-json
+func (c *Conn) SendJSONEvent(e *Event) error {
+	return c.reply(TypeEventJSON, "", e.json())
+}
+
+// SendXMLEvent push event as text/event-xml
+func (c *Conn) SendXMLEvent(e *Event) error {
+	return c.reply(TypeEventXML, "", e.xml())
+}
+
+// SendLog push a log/data line, as FreeSWITCH does once `log` is enabled
+func (c *Conn) SendLog(level int, text string) error {
+	return c.reply(TypeLog, "Log-Level: "+strconv.Itoa(level)+"\nText-Channel: 3\n", text)
+}
+
+// Write write raw bytes, e.g. a malformed frame
+func (c *Conn) Write(frame string) error {
+	c.writeLock.Lock()
+	defer c.writeLock.Unlock()
+	_, err := io.WriteString(c.conn, frame)
+	return err
+}
+
+// Disconnect send text/disconnect-notice. Unless linger, the connection is closed afterwards.
+func (c *Conn) Disconnect(linger bool) error {
+	disposition := "disconnect"
+	if linger {
+		disposition = "linger"
+	}
+	err := c.reply(TypeDisconnect, "Content-Disposition: "+disposition+"\n",
+		"Disconnected, goodbye.\nSee you at ClueCon! http://www.cluecon.com/\n")
+	if !linger {
+		c.Close()
+	}
+	return err
+}
@@ -0,0 +1,131 @@
+package esltest
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/textproto"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Event event pushed by the fake server
+type Event struct {
+	Headers textproto.MIMEHeader
+	Body    string
+}
+
+// NewEvent create event with Event-Name and header key/value pairs
+func NewEvent(name string, kv ...string) *Event {
+	e := &Event{
+		Headers: make(textproto.MIMEHeader),
+	}
+	e.Headers.Set("Event-Name", name)
+	for i := 0; i+1 < len(kv); i += 2 {
+		e.Headers.Add(kv[i], kv[i+1])
+	}
+	return e
+}
+
+// Set set header value, returns the event for chaining
+func (e *Event) Set(key, value string) *Event {
+	e.Headers.Set(key, value)
+	return e
+}
+
+// Add add header value, returns the event for chaining
+func (e *Event) Add(key, value string) *Event {
+	e.Headers.Add(key, value)
+	return e
+}
+
+// WithBody set event body, returns the event for chaining
+func (e *Event) WithBody(body string) *Event {
+	e.Body = body
+	return e
+}
+
+func (e *Event) sortedKeys() []string {
+	keys := make([]string, 0, len(e.Headers))
+	for k := range e.Headers {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// plain encode event as text/event-plain body
+func (e *Event) plain() string {
+	var builder strings.Builder
+	builder.WriteString(e.headerLines())
+	if len(e.Body) > 0 {
+		builder.WriteString("Content-Length: " + strconv.Itoa(len(e.Body)) + "\n\n")
+		builder.WriteString(e.Body)
+	} else {
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// json encode event as text/event-json body
+func (e *Event) json() string {
+	m := make(map[string]interface{}, len(e.Headers)+2)
+	for k, v := range e.Headers {
+		if len(v) == 1 {
+			m[k] = v[0]
+		} else {
+			m[k] = v
+		}
+	}
+	if len(e.Body) > 0 {
+		m["Content-Length"] = strconv.Itoa(len(e.Body))
+		m["_body"] = e.Body
+	}
+	b, _ := json.Marshal(m)
+	return string(b)
+}
+
+// xml encode event as text/event-xml body
+func (e *Event) xml() string {
+	var builder strings.Builder
+	builder.WriteString("<event>\n  <headers>\n")
+	for _, k := range e.sortedKeys() {
+		for _, v := range e.Headers[k] {
+			builder.WriteString("    <" + k + ">")
+			xml.EscapeText(&builder, []byte(url.PathEscape(v)))
+			builder.WriteString("</" + k + ">\n")
+		}
+	}
+	if len(e.Body) > 0 {
+		builder.WriteString("    <Content-Length>" + strconv.Itoa(len(e.Body)) + "</Content-Length>\n")
+	}
+	builder.WriteString("  </headers>\n")
+	if len(e.Body) > 0 {
+		builder.WriteString("  <body>")
+		xml.EscapeText(&builder, []byte(e.Body))
+		builder.WriteString("</body>\n")
+	}
+	builder.WriteString("</event>")
+	return builder.String()
+}
+
+// headerLines encode headers as url encoded `Key: Value` lines
+func (e *Event) headerLines() string {
+	var builder strings.Builder
+	for _, k := range e.sortedKeys() {
+		for _, v := range e.Headers[k] {
+			builder.WriteString(fmt.Sprintf("%s: %s\n", k, url.PathEscape(v)))
+		}
+	}
+	return builder.String()
+}
+
+// NewUUID generate a random uuid string like FreeSWITCH does
+func NewUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
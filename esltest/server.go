@@ -0,0 +1,265 @@
+// Package esltest provides a scriptable fake FreeSWITCH mod_event_socket,
+// so inbound clients and outbound connections can be tested without a real switch.
+package esltest
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultPassword default event socket password
+const DefaultPassword = "ClueCon"
+
+// APIHandler returns the api/response (or BACKGROUND_JOB) body for `api`/`bgapi` arguments
+type APIHandler func(args string) string
+
+// AppHandler returns the Application-Response of a dialplan application executed with sendmsg,
+// and extra CHANNEL_EXECUTE_COMPLETE headers as key/value pairs
+type AppHandler func(uuid, args string) (response string, kv []string)
+
+// Handler handles a command received by the fake server.
+// Return false to fall back to the default handling of the command.
+type Handler func(c *Conn, cmd *Command) bool
+
+// Server fake FreeSWITCH event socket listening on a loopback address
+type Server struct {
+	// Password password accepted by `auth`
+	Password string
+	// Users user@domain to password accepted by `userauth`
+	Users map[string]string
+	// JobDelay delay before a bgapi BACKGROUND_JOB event is sent,
+	// mimics FreeSWITCH running the job in its own thread
+	JobDelay time.Duration
+
+	listener  net.Listener
+	mtx       sync.RWMutex
+	apis      map[string]APIHandler
+	apps      map[string]AppHandler
+	handlers  map[string]Handler
+	conns     map[*Conn]struct{}
+	connChn   chan *Conn
+	wg        sync.WaitGroup
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+// NewServer start a fake event socket server on 127.0.0.1 with a random port
+func NewServer(password string) (*Server, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	return newServer(password, listener), nil
+}
+
+// NewTLSServer start a fake event socket server accepting TLS connections, e.g. behind stunnel
+func NewTLSServer(password string, config *tls.Config) (*Server, error) {
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", config)
+	if err != nil {
+		return nil, err
+	}
+	return newServer(password, listener), nil
+}
+
+func newServer(password string, listener net.Listener) *Server {
+	s := &Server{
+		Password: password,
+		Users:    make(map[string]string),
+		JobDelay: 20 * time.Millisecond,
+		listener: listener,
+		apis:     make(map[string]APIHandler),
+		apps:     make(map[string]AppHandler),
+		handlers: make(map[string]Handler),
+		conns:    make(map[*Conn]struct{}),
+		connChn:  make(chan *Conn, 64),
+		closed:   make(chan struct{}),
+	}
+	s.wg.Add(1)
+	go s.acceptLoop()
+	return s
+}
+
+// Addr listening address, host:port
+func (s *Server) Addr() string {
+	return s.listener.Addr().String()
+}
+
+// Host listening host
+func (s *Server) Host() string {
+	host, _, _ := net.SplitHostPort(s.Addr())
+	return host
+}
+
+// Port listening port
+func (s *Server) Port() uint16 {
+	_, port, _ := net.SplitHostPort(s.Addr())
+	p, _ := strconv.Atoi(port)
+	return uint16(p)
+}
+
+// HandleAPI set the response of `api <name>` and `bgapi <name>`
+func (s *Server) HandleAPI(name string, fn APIHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.apis[name] = fn
+}
+
+// HandleApp complete `sendmsg` executions of app with a CHANNEL_EXECUTE_COMPLETE event after JobDelay
+func (s *Server) HandleApp(name string, fn AppHandler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.apps[name] = fn
+}
+
+// Handle override the handling of a command by its name, e.g. "sendmsg" or "filter"
+func (s *Server) Handle(name string, fn Handler) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.handlers[name] = fn
+}
+
+func (s *Server) api(name string) (APIHandler, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	fn, ok := s.apis[name]
+	return fn, ok
+}
+
+func (s *Server) app(name string) (AppHandler, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	fn, ok := s.apps[name]
+	return fn, ok
+}
+
+func (s *Server) handler(name string) (Handler, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	fn, ok := s.handlers[name]
+	return fn, ok
+}
+
+func (s *Server) acceptLoop() {
+	defer s.wg.Done()
+	for {
+		c, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		conn := s.newConn(c, false)
+		conn.sendAuthRequest()
+		s.track(conn)
+	}
+}
+
+// DialOutbound dial an outbound event socket server, acting as FreeSWITCH executing
+// the `socket` application. channelData is replied to `connect`, it may be nil.
+func (s *Server) DialOutbound(addr string, channelData *Event) (*Conn, error) {
+	c, err := net.DialTimeout("tcp", addr, 3*time.Second)
+	if err != nil {
+		return nil, err
+	}
+	return s.outbound(c, channelData), nil
+}
+
+// DialOutboundTLS like DialOutbound over TLS
+func (s *Server) DialOutboundTLS(addr string, channelData *Event, config *tls.Config) (*Conn, error) {
+	dialer := &net.Dialer{Timeout: 3 * time.Second}
+	c, err := tls.DialWithDialer(dialer, "tcp", addr, config)
+	if err != nil {
+		return nil, err
+	}
+	return s.outbound(c, channelData), nil
+}
+
+func (s *Server) outbound(c net.Conn, channelData *Event) *Conn {
+	conn := s.newConn(c, true)
+	if channelData == nil {
+		channelData = NewEvent("CHANNEL_DATA", "Unique-ID", NewUUID())
+	}
+	conn.channelData = channelData
+	s.track(conn)
+	return conn
+}
+
+func (s *Server) track(conn *Conn) {
+	s.mtx.Lock()
+	s.conns[conn] = struct{}{}
+	s.mtx.Unlock()
+
+	select {
+	case s.connChn <- conn:
+	default:
+	}
+
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+		conn.serve()
+		s.mtx.Lock()
+		delete(s.conns, conn)
+		s.mtx.Unlock()
+	}()
+}
+
+// WaitConn wait for the next accepted (or outbound dialed) connection
+func (s *Server) WaitConn(ctx context.Context) (*Conn, error) {
+	select {
+	case c := <-s.connChn:
+		return c, nil
+	case <-s.closed:
+		return nil, errors.New("server closed")
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// Conns currently open connections
+func (s *Server) Conns() []*Conn {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	conns := make([]*Conn, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// SendEvent push event to every connection that has enabled events,
+// encoded in the format each connection subscribed with
+func (s *Server) SendEvent(e *Event) error {
+	var err error
+	for _, c := range s.Conns() {
+		if !c.EventsEnabled() {
+			continue
+		}
+		if e := c.SendEvent(e); e != nil {
+			err = e
+		}
+	}
+	return err
+}
+
+// Close stop listening and close all connections
+func (s *Server) Close() {
+	s.closeOnce.Do(func() {
+		close(s.closed)
+		s.listener.Close()
+		for _, c := range s.Conns() {
+			c.Close()
+		}
+		s.wg.Wait()
+	})
+}
@@ -0,0 +1,101 @@
+package esltest
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"time"
+)
+
+// Certificates throwaway CA with a server certificate for 127.0.0.1/localhost and a client certificate
+type Certificates struct {
+	// Pool pool containing the CA
+	Pool   *x509.CertPool
+	Server tls.Certificate
+	Client tls.Certificate
+}
+
+// NewCertificates generate a CA and certificates signed by it, valid for one day
+func NewCertificates() (*Certificates, error) {
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "esltest CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		return nil, err
+	}
+	if ca, err = x509.ParseCertificate(der); err != nil {
+		return nil, err
+	}
+
+	certs := &Certificates{Pool: x509.NewCertPool()}
+	certs.Pool.AddCert(ca)
+	if certs.Server, err = signCertificate(ca, caKey, 2, "esltest server", x509.ExtKeyUsageServerAuth); err != nil {
+		return nil, err
+	}
+	if certs.Client, err = signCertificate(ca, caKey, 3, "esltest client", x509.ExtKeyUsageClientAuth); err != nil {
+		return nil, err
+	}
+	return certs, nil
+}
+
+func signCertificate(ca *x509.Certificate, caKey *ecdsa.PrivateKey, serial int64, name string,
+	usage x509.ExtKeyUsage) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(serial),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    ca.NotBefore,
+		NotAfter:     ca.NotAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, ca, &key.PublicKey, caKey)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// ServerConfig tls config of a server, requiring a client certificate signed by the CA when mutual
+func (c *Certificates) ServerConfig(mutual bool) *tls.Config {
+	config := &tls.Config{
+		Certificates: []tls.Certificate{c.Server},
+	}
+	if mutual {
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+		config.ClientCAs = c.Pool
+	}
+	return config
+}
+
+// ClientConfig tls config of a client trusting the CA, presenting the client certificate when mutual
+func (c *Certificates) ClientConfig(mutual bool) *tls.Config {
+	config := &tls.Config{
+		RootCAs: c.Pool,
+	}
+	if mutual {
+		config.Certificates = []tls.Certificate{c.Client}
+	}
+	return config
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/textproto"
@@ -11,6 +12,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 // EventHandler event handler callback
@@ -23,6 +25,17 @@ const EventListenAll = "ALL"
 type Event struct {
 	Headers textproto.MIMEHeader
 	Body    []byte
+	// AppLog <app_log> section of xml events, empty for other formats
+	AppLog []AppLogEntry
+
+	raw map[string]interface{}
+}
+
+// AppLogEntry application executed on the channel, from the xml event <app_log> section
+type AppLogEntry struct {
+	Name  string
+	Data  string
+	Stamp string
 }
 
 // BgCallback bgapi callback func
@@ -34,31 +47,80 @@ type EventCallback func(eventName string, header map[string]string, body string)
 // HeaderFilterCallback filter by header field callback func
 type HeaderFilterCallback func(name, value string, header map[string]string, body string)
 
+type bgJob struct {
+	cb EventHandler
+	// fail called when the job expires or its connection closes, nil for plain callbacks
+	fail    func(err error)
+	expires time.Time
+}
+
 type bgFilter struct {
 	sync.Mutex
-	cb map[string]EventHandler
+	cb      map[string]*bgJob
+	ttl     time.Duration
+	expired uint64
+}
+
+type eventHandlerItem struct {
+	cb EventHandler
 }
 
 type eventFilter struct {
 	sync.RWMutex
-	cb map[string]EventHandler
+	cb map[string][]*eventHandlerItem
 }
 
 type headerFilterItem struct {
+	// header and value of FilterHeader handlers, empty for FilterMatch
 	header string
 	value  string
+	match  Matcher
 	cb     EventHandler
 }
 
+// Subscription handle of a handler added by FilterEvent, FilterHeader or FilterMatch
+type Subscription struct {
+	once   sync.Once
+	remove func()
+}
+
+// Unsubscribe remove the handler, it is not called for events dispatched afterwards.
+// Safe to call more than once and from the handler itself.
+func (s *Subscription) Unsubscribe() {
+	if s == nil {
+		return
+	}
+	s.once.Do(s.remove)
+}
+
 type headerFilter struct {
 	sync.RWMutex
 	cb []*headerFilterItem
 }
 
+// filter handlers and jobs of a connection, shared by the successive connections of a Client
 type filter struct {
 	bgapi  bgFilter
+	exec   execFilter
+	dtmf   dtmfFilter
 	event  eventFilter
 	header headerFilter
+
+	// mtx guards tracker and dispatch
+	mtx     sync.RWMutex
+	tracker *ChannelTracker
+	// dispatch runs the handlers when set, otherwise they run on the event loop
+	dispatch *dispatcher
+}
+
+func newFilter() *filter {
+	return &filter{
+		bgapi:  bgFilter{cb: make(map[string]*bgJob), ttl: DefaultJobTTL},
+		exec:   execFilter{waiters: make(map[string]*execWaiter)},
+		dtmf:   dtmfFilter{readers: make(map[string][]*DTMFReader)},
+		event:  eventFilter{cb: make(map[string][]*eventHandlerItem)},
+		header: headerFilter{cb: make([]*headerFilterItem, 0, 5)},
+	}
 }
 
 func readPlainEvent(body []byte) (*Event, error) {
@@ -88,46 +150,158 @@ func readPlainEvent(body []byte) (*Event, error) {
 	return event, nil
 }
 
-// TODO: Needs processing
+// xmlNode generic xml element, used to walk text/event-xml bodies
+type xmlNode struct {
+	XMLName xml.Name
+	Attrs   []xml.Attr `xml:",any,attr"`
+	Content string     `xml:",chardata"`
+	Nodes   []xmlNode  `xml:",any"`
+}
+
+func (n xmlNode) attr(name string) string {
+	for _, a := range n.Attrs {
+		if a.Name.Local == name {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// readXMLEvent decode text/event-xml body:
+//
+//	<event>
+//	  <headers><Event-Name>CHANNEL_CREATE</Event-Name>...</headers>
+//	  <variables><sip_call_id>...</sip_call_id>...</variables>
+//	  <app_log><application app_name="..." app_data="..."/>...</app_log>
+//	  <body>...</body>
+//	</event>
+//
+// header values stay url encoded like plain events, GetHeader decodes them.
 func readXMLEvent(body []byte) (*Event, error) {
-	return nil, ErrNotImplement
+	var root xmlNode
+	if err := xml.Unmarshal(body, &root); err != nil {
+		return nil, err
+	}
+	if root.XMLName.Local != "event" {
+		return nil, fmt.Errorf("unexpected xml event root element <%s>", root.XMLName.Local)
+	}
+
+	event := &Event{
+		Headers: textproto.MIMEHeader{},
+	}
+	for _, n := range root.Nodes {
+		switch n.XMLName.Local {
+		case "headers":
+			for _, h := range n.Nodes {
+				event.Headers.Add(h.XMLName.Local, h.Content)
+			}
+		case "body":
+			event.Body = []byte(n.Content)
+		case "variables":
+			for _, v := range n.Nodes {
+				event.Headers.Add("Variable_"+v.XMLName.Local, v.Content)
+			}
+		case "app_log":
+			for _, app := range n.Nodes {
+				event.AppLog = append(event.AppLog, AppLogEntry{
+					Name:  app.attr("app_name"),
+					Data:  app.attr("app_data"),
+					Stamp: app.attr("app_stamp"),
+				})
+			}
+		default:
+			if len(n.Nodes) == 0 {
+				event.Headers.Add(n.XMLName.Local, n.Content)
+				continue
+			}
+			for _, h := range n.Nodes {
+				event.Headers.Add(h.XMLName.Local, h.Content)
+			}
+		}
+	}
+	return event, nil
 }
 
-// TODO: Needs processing
+// readJSONEvent decode text/event-json body. Arrays (i.e. Event CHANNEL_EXECUTE_COMPLETE -
+// "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]) become multi-valued headers,
+// numbers and bools their string form. The decoded object is kept for Event.Raw.
 func readJSONEvent(body []byte) (*Event, error) {
-	// OK, what is missing here is a way to interpret other JSON types - it expects string only (understandably
-		// because the FS events are generally "string: string") - extract into empty interface and migrate only strings.
-		// i.e. Event CHANNEL_EXECUTE_COMPLETE - "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]
-		var decoded map[string]interface{}
+	decoded, err := decodeJSONEvent(body)
+	if err != nil {
+		return nil, err
+	}
 
-		if err := json.Unmarshal(body, &decoded); err != nil {
-			return nil, err
-		}
+	event := &Event{
+		Headers: textproto.MIMEHeader{},
+		raw:     decoded,
+	}
 
-		event := &Event{
-			Headers: textproto.MIMEHeader{},
+	for k, v := range decoded {
+		if k == "_body" {
+			continue
 		}
+		values, ok := jsonHeaderValues(v)
+		if !ok {
+			logger.Warnf("Removed null property (%s)", k)
+			continue
+		}
+		key := textproto.CanonicalMIMEHeaderKey(k)
+		event.Headers[key] = append(event.Headers[key], values...)
+	}
 
-		// Copy back in:
-		for k, v := range decoded {
-			switch v := v.(type) {
-			case string:
-				event.Headers[textproto.CanonicalMIMEHeaderKey(k)] = append(event.Headers[textproto.CanonicalMIMEHeaderKey(k)], v)
-			case []string:
-				event.Headers[textproto.CanonicalMIMEHeaderKey(k)] = append(event.Headers[textproto.CanonicalMIMEHeaderKey(k)], v...)
-			default:
-				//delete(m.Headers, k)
-				logger.Warnf("Removed non-string property (%s)", k)
+	if v, ok := decoded["_body"].(string); ok {
+		event.Body = []byte(v)
+	} else {
+		event.Body = []byte("")
+	}
+	return event, nil
+}
+
+// decodeJSONEvent decode json event object, numbers are kept as json.Number
+func decodeJSONEvent(body []byte) (map[string]interface{}, error) {
+	var decoded map[string]interface{}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&decoded); err != nil {
+		return nil, err
+	}
+	return decoded, nil
+}
+
+// jsonHeaderValues convert a decoded json property to header values, false for null
+func jsonHeaderValues(v interface{}) ([]string, bool) {
+	switch v := v.(type) {
+	case string:
+		return []string{v}, true
+	case json.Number:
+		return []string{v.String()}, true
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}, true
+	case bool:
+		return []string{strconv.FormatBool(v)}, true
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if vv, ok := jsonHeaderValues(item); ok {
+				values = append(values, vv...)
 			}
 		}
-
-		if v := event.Headers["_body"]; len(v) > 0 && v[0] != "" {
-			event.Body = []byte(v[0])
-			delete(event.Headers, "_body")
-		} else {
-			event.Body = []byte("")
+		return values, true
+	case nil:
+		return nil, false
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return nil, false
 		}
-		return event, nil
+		return []string{string(b)}, true
+	}
+}
+
+// Raw decoded json object of a text/event-json event (numbers as json.Number), nil for other formats
+func (e Event) Raw() map[string]interface{} {
+	return e.raw
 }
 
 // GetName Helper function that returns the event name header
@@ -141,9 +315,13 @@ func (e Event) HasHeader(header string) bool {
 	return ok
 }
 
-// GetHeader Helper function that calls e.Header.Get
+// GetHeader Helper function that calls e.Header.Get. Result gets passed through url.PathUnescape,
+// values which are not url encoded (json events) are returned as is
 func (e Event) GetHeader(header string) string {
-	value, _ := url.PathUnescape(e.Headers.Get(header))
+	value := e.Headers.Get(header)
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		return unescaped
+	}
 	return value
 }
 
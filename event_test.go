@@ -0,0 +1,136 @@
+package esl
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+var (
+	xmlEvent1 = `<event>
+  <headers>
+    <Event-Name>CHANNEL_HANGUP_COMPLETE</Event-Name>
+    <Core-UUID>ab97dc06-9369-11ea-8aeb-65fa8de67664</Core-UUID>
+    <Event-Date-Local>2020-05-14%2017%3A35%3A30</Event-Date-Local>
+    <Unique-ID>0f3a1f2c-6c43-4c36-b6c8-4c2a9e8e4f21</Unique-ID>
+    <Caller-Caller-ID-Name>Tom%20%26%20Jerry</Caller-Caller-ID-Name>
+    <variable_DP_MATCH>ARRAY%3A%3A1000%7C%3A1000</variable_DP_MATCH>
+    <Content-Length>10</Content-Length>
+  </headers>
+  <variables>
+    <sip_call_id>abc%40192.168.1.2</sip_call_id>
+    <billsec>12</billsec>
+  </variables>
+  <app_log>
+    <application app_name="answer" app_data="" app_stamp="1589448930194500"></application>
+    <application app_name="playback" app_data="/tmp/a.wav" app_stamp="1589448931194500"></application>
+  </app_log>
+  <body>hello &amp; </body>
+</event>`
+
+	xmlEvent2 = `<event><headers><Event-Name>BACKGROUND_JOB</Event-Name></headers></event>`
+)
+
+func Test_readXMLEvent(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		appLog  []AppLogEntry
+		wantErr bool
+	}{
+		{"hangup", xmlEvent1, map[string]string{
+			"Event-Name":            "CHANNEL_HANGUP_COMPLETE",
+			"Event-Date-Local":      "2020-05-14 17:35:30",
+			"Unique-ID":             "0f3a1f2c-6c43-4c36-b6c8-4c2a9e8e4f21",
+			"Caller-Caller-ID-Name": "Tom & Jerry",
+			"Variable_DP_MATCH":     "ARRAY::1000|:1000",
+			"Variable_sip_call_id":  "abc@192.168.1.2",
+			"Variable_billsec":      "12",
+		}, []AppLogEntry{
+			{"answer", "", "1589448930194500"},
+			{"playback", "/tmp/a.wav", "1589448931194500"},
+		}, false},
+		{"no body", xmlEvent2, map[string]string{"Event-Name": "BACKGROUND_JOB"}, nil, false},
+		{"not event", "<foo></foo>", nil, nil, true},
+		{"bad xml", "<event><headers>", nil, nil, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := readXMLEvent([]byte(tt.body))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("readXMLEvent() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			for k, v := range tt.headers {
+				if got.GetHeader(k) != v {
+					t.Errorf("GetHeader(%q) = %q, want %q", k, got.GetHeader(k), v)
+				}
+			}
+			if len(got.AppLog) != len(tt.appLog) {
+				t.Fatalf("AppLog = %#v, want %#v", got.AppLog, tt.appLog)
+			}
+			for i := range tt.appLog {
+				if got.AppLog[i] != tt.appLog[i] {
+					t.Errorf("AppLog[%d] = %#v, want %#v", i, got.AppLog[i], tt.appLog[i])
+				}
+			}
+		})
+	}
+	e, _ := readXMLEvent([]byte(xmlEvent1))
+	if string(e.Body) != "hello & " {
+		t.Errorf("Body = %q", e.Body)
+	}
+	if e.GetVariable("sip_call_id") != "abc@192.168.1.2" {
+		t.Errorf("GetVariable(sip_call_id) = %q", e.GetVariable("sip_call_id"))
+	}
+}
+
+var jsonEvent1 = `{"Event-Name":"CHANNEL_EXECUTE_COMPLETE","Unique-ID":"0f3a1f2c-6c43-4c36-b6c8-4c2a9e8e4f21",` +
+	`"variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"],"variable_loops":3,"variable_rate":0.5,` +
+	`"variable_bypass_media":false,"variable_nothing":null,"variable_ratio":"50%","_body":"body"}`
+
+func Test_readJSONEvent(t *testing.T) {
+	e, err := readJSONEvent([]byte(jsonEvent1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		header string
+		want   []string
+	}{
+		{"Event-Name", []string{"CHANNEL_EXECUTE_COMPLETE"}},
+		{"Variable_DP_MATCH", []string{"a=rtpmap:101 telephone-event/8000", "101"}},
+		{"Variable_loops", []string{"3"}},
+		{"Variable_rate", []string{"0.5"}},
+		{"Variable_bypass_media", []string{"false"}},
+		{"Variable_nothing", nil},
+		{"Variable_ratio", []string{"50%"}},
+		{"_body", nil},
+	}
+	for _, tt := range tests {
+		got := e.Headers.Values(tt.header)
+		if len(got) != len(tt.want) {
+			t.Errorf("Headers[%q] = %q, want %q", tt.header, got, tt.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Errorf("Headers[%q] = %q, want %q", tt.header, got, tt.want)
+			}
+		}
+	}
+	if e.GetVariable("ratio") != "50%" {
+		t.Errorf("GetVariable(ratio) = %q", e.GetVariable("ratio"))
+	}
+	if string(e.Body) != "body" {
+		t.Errorf("Body = %q", e.Body)
+	}
+	if n, ok := e.Raw()["variable_loops"].(json.Number); !ok || n.String() != "3" {
+		t.Errorf("Raw()[variable_loops] = %#v", e.Raw()["variable_loops"])
+	}
+	if _, ok := e.Raw()["variable_DP_MATCH"].([]interface{}); !ok {
+		t.Errorf("Raw()[variable_DP_MATCH] = %#v", e.Raw()["variable_DP_MATCH"])
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"github.com/zhifeichen/esl/v2"
 	"github.com/zhifeichen/esl/v2/command"
@@ -22,114 +23,87 @@ func main() {
 	log.Init(log.NewOptions(
 		log.Level("trace"),
 	))
+	server := esl.NewOutboundServer(":8888", esl.SessionHandler(esl.SessionOptions{
+		MyEvents:      true,
+		Linger:        true,
+		LingerSeconds: 10,
+	}, handle))
 	go func() {
-		log.Fatal(esl.ListenAndServe(":8888", handle))
+		if err := server.ListenAndServe(); err != esl.ErrServerClosed {
+			log.Fatal(err)
+		}
 	}()
 
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Info("shutdown esl outbound server...")
-	esl.Shutdown()
-}
-
-func handle(ctx context.Context, conn *esl.Connection) {
-	response, err := conn.SendCommand(ctx, command.Connect{})
-	if err != nil {
-		log.Errorf("Error connecting to %s error %s\n", conn.RemoteAddr().String(), err.Error())
-		conn.Close()
-		return
+	// let the calls in progress finish
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := server.Shutdown(ctx); err != nil {
+		log.Error(err)
 	}
+}
 
-	conn.EnableEvent(ctx, "ALL")
-	// eventChn := make(chan *esl.Event, 10)
-	// go eventHandle(ctx, conn, eventChn)
-	conn.FilterEvent(esl.EventListenAll, func(event *esl.Event) {
+func handle(ctx context.Context, session *esl.OutboundSession) {
+	conn := session.Connection
+	session.FilterEvent(esl.EventListenAll, func(event *esl.Event) {
 		log.Infof("got event %s\n", event.GetName())
-		// eventChn <- e
 		go eventHandle(ctx, conn, event)
 	})
 
-	_, err = conn.SendCommand(ctx, command.Linger{
-		Enabled:  true,
-		Duration: 10,
-	})
+	data := session.ChannelData
+	log.Infof("caller: %s\ncallee: %s\nchannelId: %s\nsdp: %s\ncallid: %s\n", data.CallerIDNumber,
+		data.DestinationNumber, data.UUID, data.GetVariable("switch_r_sdp"), data.GetVariable("sip_call_id"))
+	caller, callee, uniqueID := data.CallerIDNumber, data.DestinationNumber, data.UUID
+
+	// set call-timeout
+	err := session.Set(ctx, "call_timeout", "30")
 	if err != nil {
 		log.Error(err)
 		return
 	}
 
-	log.Infof("Got connection!: %#v\n", response)
-	log.Infof("response is ok? %t\n", response.IsOk())
-	log.Infof("response reply text: %s\n", response.GetReply())
-
-	eventName := response.GetHeader("Event-Name")
-	log.Infof("event name: %s\n", eventName)
-
-	if eventName == "CHANNEL_DATA" {
-		caller := response.GetHeader("Channel-Caller-Id-Number")
-		callee := response.GetHeader("Channel-Destination-Number")
-		uniqueID := response.ChannelUUID()
-		sdp := response.GetVariable("switch_r_sdp")
-		callID := response.GetVariable("sip_call_id")
-
-		log.Infof("caller: %s\ncallee: %s\nchannelId: %s\nsdp: %s\ncallid: %s\n", caller, callee, uniqueID, sdp, callID)
-
-		conn.SendCommand(ctx, command.Filter{
-			EventHeader: "Unique-Id",
-			FilterValue: uniqueID,
-		})
-		conn.SendCommand(ctx, command.Filter{
-			EventHeader: "Event-Name",
-			FilterValue: "BACKGROUND_JOB",
-		})
-
-		// set call-timeout
-		err = conn.Set(ctx, "call_timeout", "30", uniqueID)
-		if err != nil {
-			log.Error(err)
-			return
-		}
+	err = session.Export(ctx, "hangup_after_bridge", "true")
+	if err != nil {
+		log.Error(err)
+		return
+	}
 
-		err = conn.Export(ctx, "hangup_after_bridge", "true", uniqueID)
-		if err != nil {
-			log.Error(err)
-			return
-		}
+	temp, err := conn.SendCommand(ctx, command.API{
+		Command:   "sofia_contact",
+		Arguments: caller,
+		Background: true,
+	}, func(e *esl.Event){
+		log.Debugf("background job: %s", e.GetName())
+	})
 
-		temp, err := conn.SendCommand(ctx, command.API{
-			Command:   "sofia_contact",
-			Arguments: caller,
-			Background: true,
-		}, func(e *esl.Event){
-			log.Debugf("background job: %s", e.GetName())
-		})
+	if err != nil {
+		log.Error(err)
+		return
+	}
+	log.Debug(temp);
 
-		if err != nil {
-			log.Error(err)
-			return
-		}
-		log.Debug(temp);
 
+	calleeContactResp, err := conn.SendCommand(ctx, command.API{
+		Command:   "sofia_contact",
+		Arguments: callee,
+	})
 
-		calleeContactResp, err := conn.SendCommand(ctx, command.API{
-			Command:   "sofia_contact",
-			Arguments: callee,
-		})
+	if err != nil {
+		log.Error(err)
+		return
+	}
 
-		if err != nil {
-			log.Error(err)
-			return
-		}
+	conn.SendCommand(ctx, &call.Execute{
+		UUID:    uniqueID,
+		AppName: "bridge",
+		AppArgs: string(calleeContactResp.Body),
+		Sync:    true,
+	})
+	log.Info("send bridge...")
 
-		conn.SendCommand(ctx, &call.Execute{
-			UUID:    uniqueID,
-			AppName: "bridge",
-			AppArgs: string(calleeContactResp.Body),
-			Sync:    true,
-		})
-		log.Info("send bridge...")
-	}
 
 	<-ctx.Done()
 	log.Info("handle ctx done")
@@ -0,0 +1,148 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/zhifeichen/esl/v2/command/call"
+)
+
+// events completing or aborting ExecuteWait
+const (
+	EventChannelExecute         = "CHANNEL_EXECUTE"
+	EventChannelExecuteComplete = "CHANNEL_EXECUTE_COMPLETE"
+	EventChannelDestroy         = "CHANNEL_DESTROY"
+)
+
+// ExecuteEvents events ExecuteWait needs on an inbound connection, outbound `myevents` includes them
+var ExecuteEvents = []string{
+	EventChannelExecuteComplete,
+	EventChannelHangup,
+	EventChannelHangupComplete,
+	EventChannelDestroy,
+}
+
+// ExecuteResult completion of an application executed by ExecuteWait
+type ExecuteResult struct {
+	// UUID channel uuid
+	UUID string
+	// AppUUID Application-UUID correlating the sendmsg with its completion
+	AppUUID     string
+	Application string
+	// Data Application-Data, the arguments
+	Data string
+	// Response Application-Response, e.g. FILE PLAYED
+	Response string
+	// Event the CHANNEL_EXECUTE_COMPLETE event, carrying the channel variables set by the application
+	Event *Event
+}
+
+// GetVariable channel variable from the completion event
+func (r *ExecuteResult) GetVariable(name string) string {
+	return r.Event.GetVariable(name)
+}
+
+type execWaiter struct {
+	channel string
+	done    chan struct{}
+	once    sync.Once
+	result  *ExecuteResult
+	err     error
+}
+
+func (w *execWaiter) finish(result *ExecuteResult, err error) {
+	w.once.Do(func() {
+		w.result, w.err = result, err
+		close(w.done)
+	})
+}
+
+type execFilter struct {
+	sync.Mutex
+	waiters map[string]*execWaiter
+}
+
+// ExecuteWait execute app on channel uuid and wait for its CHANNEL_EXECUTE_COMPLETE. ErrChannelHangup is
+// returned when the channel hangs up first. The connection must receive ExecuteEvents of the channel,
+// with `myevents` on outbound connections or an event subscription on inbound ones.
+func (c *Connection) ExecuteWait(ctx context.Context, uuid, app, args string) (*ExecuteResult, error) {
+	appUUID := newUUID()
+	w := &execWaiter{
+		channel: uuid,
+		done:    make(chan struct{}),
+	}
+	c.filter.exec.Lock()
+	c.filter.exec.waiters[appUUID] = w
+	c.filter.exec.Unlock()
+	defer func() {
+		c.filter.exec.Lock()
+		delete(c.filter.exec.waiters, appUUID)
+		c.filter.exec.Unlock()
+	}()
+
+	resp, err := c.SendCommand(ctx, &call.Execute{
+		UUID:    uuid,
+		AppName: app,
+		AppArgs: args,
+		AppUUID: appUUID,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsOk() {
+		return nil, replyError(resp)
+	}
+
+	select {
+	case <-w.done:
+		return w.result, w.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *Connection) notifyExecWaiters(e *Event) {
+	switch e.GetName() {
+	case EventChannelExecuteComplete:
+		appUUID := e.GetHeader("Application-UUID")
+		c.filter.exec.Lock()
+		w, ok := c.filter.exec.waiters[appUUID]
+		c.filter.exec.Unlock()
+		if ok {
+			w.finish(&ExecuteResult{
+				UUID:        e.ChannelUUID(),
+				AppUUID:     appUUID,
+				Application: e.GetHeader("Application"),
+				Data:        e.GetHeader("Application-Data"),
+				Response:    e.GetHeader("Application-Response"),
+				Event:       e,
+			}, nil)
+		}
+	case EventChannelHangup, EventChannelHangupComplete, EventChannelDestroy:
+		uuid := e.ChannelUUID()
+		cause := e.GetHeader("Hangup-Cause")
+		c.filter.exec.Lock()
+		defer c.filter.exec.Unlock()
+
+		for _, w := range c.filter.exec.waiters {
+			if w.channel == uuid {
+				w.finish(nil, fmt.Errorf("%s: %w", cause, ErrChannelHangup))
+			}
+		}
+	}
+}
+
+func (c *Connection) failExecWaiters(err error) {
+	c.filter.exec.Lock()
+	defer c.filter.exec.Unlock()
+
+	for _, w := range c.filter.exec.waiters {
+		w.finish(nil, err)
+	}
+}
+
+// ExecuteWait execute app on the call and wait for it to complete
+func (s *OutboundSession) ExecuteWait(ctx context.Context, app, args string) (*ExecuteResult, error) {
+	return s.Connection.ExecuteWait(ctx, s.UUID(), app, args)
+}
@@ -0,0 +1,61 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestConnection_ExecuteWait(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleApp("playback", func(uuid, args string) (string, []string) {
+		return "FILE PLAYED", []string{"variable_playback_seconds", "3"}
+	})
+	client := newTestClient(t, server, "json", 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.EnableEvent(ctx, ExecuteEvents...); err != nil {
+		t.Fatal(err)
+	}
+	result, err := client.ExecuteWait(ctx, "call-1", "playback", "/tmp/hello.wav")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.UUID != "call-1" || result.Application != "playback" || result.Data != "/tmp/hello.wav" ||
+		result.Response != "FILE PLAYED" || len(result.AppUUID) == 0 {
+		t.Errorf("result = %#v", result)
+	}
+	if result.GetVariable("playback_seconds") != "3" {
+		t.Errorf("playback_seconds = %q", result.GetVariable("playback_seconds"))
+	}
+	cmd, err := conn.WaitCommand(ctx, "sendmsg call-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Headers.Get("Event-UUID") != result.AppUUID {
+		t.Errorf("Event-UUID = %q, Application-UUID = %q", cmd.Headers.Get("Event-UUID"), result.AppUUID)
+	}
+
+	// park never completes, the channel hangs up
+	go func() {
+		conn.WaitCommand(ctx, "sendmsg call-2")
+		conn.SendEvent(esltest.NewEvent(EventChannelHangup, "Unique-ID", "call-2", "Hangup-Cause", "USER_BUSY"))
+	}()
+	if _, err := client.ExecuteWait(ctx, "call-2", "park", ""); !errors.Is(err, ErrChannelHangup) {
+		t.Errorf("ExecuteWait() error = %v, want %v", err, ErrChannelHangup)
+	}
+
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.ExecuteWait(short, "call-3", "park", ""); err != context.DeadlineExceeded {
+		t.Errorf("ExecuteWait() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+}
@@ -7,13 +7,41 @@ import (
 	"github.com/zhifeichen/esl/v2/command/call"
 )
 
+// event formats
+const (
+	EventFormatPlain = "plain"
+	EventFormatJSON  = "json"
+	EventFormatXML   = "xml"
+)
+
+func validEventFormat(format string) bool {
+	return format == EventFormatPlain || format == EventFormatJSON || format == EventFormatXML
+}
+
+// SetEventFormat set the format subscribed by the next EnableEvent on this connection: plain, json or xml
+func (c *Connection) SetEventFormat(format string) error {
+	if !validEventFormat(format) {
+		return ErrUnsupportedEventFormat
+	}
+	c.eventFormat = format
+	return nil
+}
+
+// EventFormat return the event format subscribed by EnableEvent
+func (c *Connection) EventFormat() string {
+	if len(c.eventFormat) == 0 {
+		return EventFormatPlain
+	}
+	return c.eventFormat
+}
+
 // EnableEvent subscribe event format and type
 func (c *Connection) EnableEvent(ctx context.Context, events ...string) error {
 	var err error
 	if c.outbound && len(events) == 0 {
-		_, err = c.SendCommand(ctx, command.MyEvents{Format: "plain"})
+		_, err = c.SendCommand(ctx, command.MyEvents{Format: c.EventFormat()})
 	} else {
-		_, err = c.SendCommand(ctx, command.Event{Format: "plain", Listen: events})
+		_, err = c.SendCommand(ctx, command.Event{Format: c.EventFormat(), Listen: events})
 	}
 	return err
 }
@@ -39,3 +67,25 @@ func (c *Connection) Export(ctx context.Context, key, value, uuid string) error
 	_, err := c.SendCommand(ctx, e)
 	return err
 }
+
+// Originate run `bgapi originate`, wait for the job and return the new channel uuid.
+// A uuid is preassigned when o.UUID is empty.
+func (c *Connection) Originate(ctx context.Context, o call.Originate) (string, error) {
+	if err := o.Validate(); err != nil {
+		return "", err
+	}
+	if len(o.UUID) == 0 {
+		o.UUID = newUUID()
+	}
+	result, err := c.BgAPI(ctx, "originate", o.Arguments())
+	if err != nil {
+		return "", err
+	}
+	if err := result.Err(); err != nil {
+		return "", err
+	}
+	if len(result.Reply) > 0 {
+		return result.Reply, nil
+	}
+	return o.UUID, nil
+}
@@ -0,0 +1,231 @@
+package esl
+
+import (
+	"context"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// Matcher event predicate used by FilterMatch, build it with HeaderEquals, HeaderPrefix, HeaderRegexp,
+// HeaderPresent, EventName, Subclass, And, Or and Not
+type Matcher interface {
+	Match(e *Event) bool
+}
+
+// serverMatcher matcher which can be expressed as server side filters
+type serverMatcher interface {
+	// filters filters letting through at least every event the matcher matches, false when not expressible
+	filters() ([]command.Filter, bool)
+}
+
+// MatchFunc adapt a function to a Matcher, it is never sent to the server
+type MatchFunc func(e *Event) bool
+
+// Match implement Matcher
+func (f MatchFunc) Match(e *Event) bool {
+	return f(e)
+}
+
+type headerMatcher struct {
+	header string
+	// match test one value of the header
+	match func(v string) bool
+	// value server side filter value, empty when not expressible
+	value string
+}
+
+func (m headerMatcher) Match(e *Event) bool {
+	values := e.Headers.Values(m.header)
+	for _, v := range values {
+		if unescaped, err := url.PathUnescape(v); err == nil {
+			v = unescaped
+		}
+		if m.match(v) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m headerMatcher) filters() ([]command.Filter, bool) {
+	if len(m.value) == 0 {
+		return nil, false
+	}
+	return []command.Filter{{EventHeader: m.header, FilterValue: m.value}}, true
+}
+
+// HeaderEquals header has value, any of the values of a repeated header may match
+func HeaderEquals(header, value string) Matcher {
+	return headerMatcher{
+		header: textproto.CanonicalMIMEHeaderKey(header),
+		match:  func(v string) bool { return v == value },
+		value:  value,
+	}
+}
+
+// HeaderPrefix header value starts with prefix
+func HeaderPrefix(header, prefix string) Matcher {
+	return headerMatcher{
+		header: textproto.CanonicalMIMEHeaderKey(header),
+		match:  func(v string) bool { return strings.HasPrefix(v, prefix) },
+		value:  "/^" + regexp.QuoteMeta(prefix) + "/",
+	}
+}
+
+// HeaderRegexp header value matches re, sent to the server as `filter <header> /<re>/`
+func HeaderRegexp(header string, re *regexp.Regexp) Matcher {
+	return headerMatcher{
+		header: textproto.CanonicalMIMEHeaderKey(header),
+		match:  re.MatchString,
+		value:  "/" + re.String() + "/",
+	}
+}
+
+// HeaderPresent event has header, whatever the value. Not expressible as a server side filter.
+func HeaderPresent(header string) Matcher {
+	header = textproto.CanonicalMIMEHeaderKey(header)
+	return MatchFunc(func(e *Event) bool {
+		_, ok := e.Headers[header]
+		return ok
+	})
+}
+
+// EventName event named name
+func EventName(name string) Matcher {
+	return HeaderEquals("Event-Name", name)
+}
+
+// Subclass CUSTOM event whose Event-Subclass matches pattern, * matches any characters, e.g. conference::*
+// or sofia::*register
+func Subclass(pattern string) Matcher {
+	if !strings.Contains(pattern, "*") {
+		return HeaderEquals("Event-Subclass", pattern)
+	}
+	expr := "^" + strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1) + "$"
+	return HeaderRegexp("Event-Subclass", regexp.MustCompile(expr))
+}
+
+type andMatcher []Matcher
+
+func (m andMatcher) Match(e *Event) bool {
+	for _, mm := range m {
+		if !mm.Match(e) {
+			return false
+		}
+	}
+	return true
+}
+
+// filters server filters are or'ed, so one expressible operand lets through a superset of the events
+func (m andMatcher) filters() ([]command.Filter, bool) {
+	for _, mm := range m {
+		if filters, ok := serverFilters(mm); ok {
+			return filters, true
+		}
+	}
+	return nil, false
+}
+
+// And all of matchers match, true when empty
+func And(matchers ...Matcher) Matcher {
+	return andMatcher(matchers)
+}
+
+type orMatcher []Matcher
+
+func (m orMatcher) Match(e *Event) bool {
+	for _, mm := range m {
+		if mm.Match(e) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m orMatcher) filters() ([]command.Filter, bool) {
+	if len(m) == 0 {
+		return nil, false
+	}
+	var filters []command.Filter
+	for _, mm := range m {
+		f, ok := serverFilters(mm)
+		if !ok {
+			return nil, false
+		}
+		filters = append(filters, f...)
+	}
+	return filters, true
+}
+
+// Or any of matchers matches, false when empty
+func Or(matchers ...Matcher) Matcher {
+	return orMatcher(matchers)
+}
+
+// Not m does not match. Not expressible as a server side filter.
+func Not(m Matcher) Matcher {
+	return MatchFunc(func(e *Event) bool {
+		return !m.Match(e)
+	})
+}
+
+func serverFilters(m Matcher) ([]command.Filter, bool) {
+	if sm, ok := m.(serverMatcher); ok {
+		return sm.filters()
+	}
+	return nil, false
+}
+
+// ServerFilters `filter` commands letting through at least every event m matches, false when m cannot be
+// expressed. The server passes an event matching any filter, so a filtered connection receives only those.
+func ServerFilters(m Matcher) ([]command.Filter, bool) {
+	filters, ok := serverFilters(m)
+	if !ok {
+		return nil, false
+	}
+	result := make([]command.Filter, 0, len(filters))
+	for _, f := range filters {
+		if !filterInSlice(f, result) {
+			result = append(result, f)
+		}
+	}
+	return result, true
+}
+
+func filterInSlice(f command.Filter, filters []command.Filter) bool {
+	for _, exist := range filters {
+		if exist == f {
+			return true
+		}
+	}
+	return false
+}
+
+// FilterMatch add a handler of the events matching m, called with the FilterHeader handlers in
+// registration order, see FilterEvent for the fan-out order
+func (c *Connection) FilterMatch(m Matcher, cb EventHandler) *Subscription {
+	return c.filter.addHeader(&headerFilterItem{match: m, cb: cb})
+}
+
+// AddServerFilter send the server side filters of m to cut the traffic to the events it may match,
+// ErrMatcherNotExpressible when m has no server side form. Filters are kept across reconnects.
+func (c *Connection) AddServerFilter(ctx context.Context, m Matcher) error {
+	filters, ok := ServerFilters(m)
+	if !ok {
+		return ErrMatcherNotExpressible
+	}
+	for _, f := range filters {
+		response, err := c.SendCommand(ctx, f)
+		if err != nil {
+			return err
+		}
+		if !response.IsOk() {
+			return replyError(response)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package esl
+
+import (
+	"context"
+	"net/textproto"
+	"reflect"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestMatcher_Match(t *testing.T) {
+	e := &Event{Headers: textproto.MIMEHeader{
+		"Event-Name":     {"CUSTOM"},
+		"Event-Subclass": {"conference::maintenance"},
+		"Caller-Number":  {"1000%20ext"},
+		"Answer-State":   {"ringing"},
+	}}
+	tests := []struct {
+		name string
+		m    Matcher
+		want bool
+	}{
+		{"equals unescaped", HeaderEquals("caller-number", "1000 ext"), true},
+		{"equals", HeaderEquals("Answer-State", "answered"), false},
+		{"prefix", HeaderPrefix("Caller-Number", "1000"), true},
+		{"regexp", HeaderRegexp("Answer-State", regexp.MustCompile("^ring")), true},
+		{"present", HeaderPresent("Answer-State"), true},
+		{"missing", HeaderPresent("Hangup-Cause"), false},
+		{"subclass wildcard", Subclass("conference::*"), true},
+		{"subclass wildcard anchored", Subclass("*::maint"), false},
+		{"subclass", Subclass("sofia::register"), false},
+		{"and", And(EventName("CUSTOM"), Subclass("conference::*")), true},
+		{"and false", And(EventName("CUSTOM"), EventName("DTMF")), false},
+		{"or", Or(EventName("DTMF"), Subclass("*maintenance")), true},
+		{"not", Not(EventName("CUSTOM")), false},
+		{"func", MatchFunc(func(e *Event) bool { return e.GetName() == "CUSTOM" }), true},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Match(e); got != tt.want {
+			t.Errorf("%s: Match() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestServerFilters(t *testing.T) {
+	tests := []struct {
+		name string
+		m    Matcher
+		want []command.Filter
+		ok   bool
+	}{
+		{"equals", EventName("DTMF"), []command.Filter{{EventHeader: "Event-Name", FilterValue: "DTMF"}}, true},
+		{"prefix", HeaderPrefix("Channel-Name", "sofia/internal"),
+			[]command.Filter{{EventHeader: "Channel-Name", FilterValue: "/^sofia/internal/"}}, true},
+		{"subclass", Subclass("conference::*"),
+			[]command.Filter{{EventHeader: "Event-Subclass", FilterValue: "/^conference::.*$/"}}, true},
+		{"or", Or(EventName("DTMF"), EventName("DTMF"), HeaderEquals("Unique-ID", "call-1")), []command.Filter{
+			{EventHeader: "Event-Name", FilterValue: "DTMF"},
+			{EventHeader: "Unique-Id", FilterValue: "call-1"},
+		}, true},
+		{"or not expressible", Or(EventName("DTMF"), Not(EventName("CUSTOM"))), nil, false},
+		{"and first expressible", And(HeaderPresent("Answer-State"), EventName("CHANNEL_ANSWER")),
+			[]command.Filter{{EventHeader: "Event-Name", FilterValue: "CHANNEL_ANSWER"}}, true},
+		{"not", Not(EventName("DTMF")), nil, false},
+		{"present", HeaderPresent("Answer-State"), nil, false},
+	}
+	for _, tt := range tests {
+		got, ok := ServerFilters(tt.m)
+		if ok != tt.ok || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: ServerFilters() = %v, %v, want %v, %v", tt.name, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestClient_FilterMatch(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, EventFormatJSON, 0)
+	conn, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	m := And(EventName("CUSTOM"), Subclass("conference::*"), Not(HeaderEquals("Action", "floor-change")))
+	if err := client.AddServerFilter(ctx, m); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := conn.WaitCommand(ctx, "filter Event-Name CUSTOM"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.AddServerFilter(ctx, Not(m)); err != ErrMatcherNotExpressible {
+		t.Errorf("AddServerFilter() error = %v, want %v", err, ErrMatcherNotExpressible)
+	}
+
+	got := make(chan string, 4)
+	sub := client.FilterMatch(m, func(e *Event) {
+		got <- e.GetHeader("Action")
+	})
+	conn.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "conference::maintenance", "Action", "floor-change"))
+	conn.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "sofia::register", "Action", "register"))
+	conn.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "conference::maintenance", "Action", "add-member"))
+	select {
+	case action := <-got:
+		if action != "add-member" {
+			t.Errorf("Action = %q, want add-member", action)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	sub.Unsubscribe()
+	conn.SendEvent(esltest.NewEvent("CUSTOM", "Event-Subclass", "conference::maintenance", "Action", "del-member"))
+	select {
+	case action := <-got:
+		t.Errorf("unexpected event %q after Unsubscribe", action)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
@@ -0,0 +1,245 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// Call media helpers of one channel, applications run with ExecuteWait so the connection must receive
+// ExecuteEvents of the channel
+type Call struct {
+	conn executor
+	// UUID channel uuid
+	UUID string
+}
+
+// executor *Connection or *Client
+type executor interface {
+	ExecuteWait(ctx context.Context, uuid, app, args string) (*ExecuteResult, error)
+	SendCommand(ctx context.Context, cmd command.Command, fn ...EventHandler) (*RawResponse, error)
+}
+
+// Call media helpers of channel uuid
+func (c *Connection) Call(uuid string) *Call {
+	return &Call{conn: c, UUID: uuid}
+}
+
+// Playback play a file, tone_stream or say/speak url and wait until it is done
+func (c *Call) Playback(ctx context.Context, file string) (*ExecuteResult, error) {
+	result, err := c.conn.ExecuteWait(ctx, c.UUID, "playback", file)
+	if err != nil {
+		return nil, err
+	}
+	if result.Response == "FILE NOT FOUND" {
+		return result, fmt.Errorf("%s %s: %w", result.Response, file, ErrCommandFailed)
+	}
+	return result, nil
+}
+
+// PromptOptions arguments of play_and_get_digits
+type PromptOptions struct {
+	MinDigits int
+	MaxDigits int
+	// Tries default 1
+	Tries int
+	// Timeout wait for the first digit after the file played, default 5s
+	Timeout time.Duration
+	// Terminators digits ending the input, default #
+	Terminators string
+	File        string
+	// InvalidFile played when the input does not match Regexp
+	InvalidFile string
+	// VarName channel variable receiving the digits, default pagd_digits
+	VarName string
+	// Regexp valid input, default \d+
+	Regexp string
+	// DigitTimeout inter digit timeout, Timeout when zero
+	DigitTimeout time.Duration
+	// TransferOnFailure extension [dialplan] [context] transferred to after the last failed try
+	TransferOnFailure string
+}
+
+func (o PromptOptions) varName() string {
+	if len(o.VarName) == 0 {
+		return "pagd_digits"
+	}
+	return o.VarName
+}
+
+// Args play_and_get_digits application arguments
+func (o PromptOptions) Args() string {
+	tries := o.Tries
+	if tries <= 0 {
+		tries = 1
+	}
+	timeout := o.Timeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	terminators := o.Terminators
+	if len(terminators) == 0 {
+		terminators = "#"
+	}
+	invalid := o.InvalidFile
+	if len(invalid) == 0 {
+		invalid = "silence_stream://250"
+	}
+	regexp := o.Regexp
+	if len(regexp) == 0 {
+		regexp = `\d+`
+	}
+	args := []string{
+		strconv.Itoa(o.MinDigits),
+		strconv.Itoa(o.MaxDigits),
+		strconv.Itoa(tries),
+		strconv.FormatInt(timeout.Milliseconds(), 10),
+		terminators,
+		o.File,
+		invalid,
+		o.varName(),
+		regexp,
+	}
+	if o.DigitTimeout > 0 || len(o.TransferOnFailure) > 0 {
+		digitTimeout := o.DigitTimeout
+		if digitTimeout <= 0 {
+			digitTimeout = timeout
+		}
+		args = append(args, strconv.FormatInt(digitTimeout.Milliseconds(), 10))
+	}
+	if len(o.TransferOnFailure) > 0 {
+		args = append(args, o.TransferOnFailure)
+	}
+	return strings.Join(args, " ")
+}
+
+// PlayAndGetDigits play a prompt and collect digits, empty when nothing valid was entered
+func (c *Call) PlayAndGetDigits(ctx context.Context, o PromptOptions) (string, error) {
+	result, err := c.conn.ExecuteWait(ctx, c.UUID, "play_and_get_digits", o.Args())
+	if err != nil {
+		return "", err
+	}
+	return result.GetVariable(o.varName()), nil
+}
+
+// SayOptions arguments of say
+type SayOptions struct {
+	// Module say module and optional language, e.g. en or en:us, default en
+	Module string
+	// Type e.g. NUMBER, ITEMS, CURRENCY, SHORT_DATE_TIME
+	Type string
+	// Method e.g. pronounced, iterated, counted, default pronounced
+	Method string
+	// Gender optional FEMININE, MASCULINE or NEUTER
+	Gender string
+	Text   string
+}
+
+// Args say application arguments
+func (o SayOptions) Args() string {
+	module := o.Module
+	if len(module) == 0 {
+		module = "en"
+	}
+	method := o.Method
+	if len(method) == 0 {
+		method = "pronounced"
+	}
+	args := []string{module, o.Type, method}
+	if len(o.Gender) > 0 {
+		args = append(args, o.Gender)
+	}
+	return strings.Join(append(args, o.Text), " ")
+}
+
+// Say say a number, date, currency... with the prerecorded sound files and wait until it is done
+func (c *Call) Say(ctx context.Context, o SayOptions) (*ExecuteResult, error) {
+	return c.conn.ExecuteWait(ctx, c.UUID, "say", o.Args())
+}
+
+// SpeakOptions arguments of speak
+type SpeakOptions struct {
+	// Engine tts engine, e.g. flite. Engine and Voice default to the tts_engine and tts_voice variables
+	Engine string
+	Voice  string
+	Text   string
+}
+
+// Args speak application arguments
+func (o SpeakOptions) Args() string {
+	if len(o.Engine) == 0 {
+		return o.Text
+	}
+	return strings.Join([]string{o.Engine, o.Voice, o.Text}, "|")
+}
+
+// Speak speak text with text to speech and wait until it is done
+func (c *Call) Speak(ctx context.Context, o SpeakOptions) (*ExecuteResult, error) {
+	return c.conn.ExecuteWait(ctx, c.UUID, "speak", o.Args())
+}
+
+// RecordOptions arguments of record
+type RecordOptions struct {
+	Path string
+	// MaxDuration time limit, unlimited when zero
+	MaxDuration time.Duration
+	// SilenceThreshold energy level below which audio is silence, 0 disables silence detection
+	SilenceThreshold int
+	// SilenceHits seconds of silence ending the recording
+	SilenceHits int
+}
+
+// Args record application arguments
+func (o RecordOptions) Args() string {
+	args := []string{o.Path}
+	if o.MaxDuration > 0 || o.SilenceThreshold > 0 {
+		args = append(args, strconv.Itoa(int(o.MaxDuration/time.Second)))
+	}
+	if o.SilenceThreshold > 0 {
+		args = append(args, strconv.Itoa(o.SilenceThreshold), strconv.Itoa(o.SilenceHits))
+	}
+	return strings.Join(args, " ")
+}
+
+// Recording metadata of a finished recording
+type Recording struct {
+	Path     string
+	Duration time.Duration
+	Samples  int
+	// Terminator dtmf digit that stopped the recording, if any
+	Terminator string
+	Result     *ExecuteResult
+}
+
+// Record record the call into a file until a terminator, silence, the time limit or StopMedia
+func (c *Call) Record(ctx context.Context, o RecordOptions) (*Recording, error) {
+	result, err := c.conn.ExecuteWait(ctx, c.UUID, "record", o.Args())
+	if err != nil {
+		return nil, err
+	}
+	ms, _ := strconv.Atoi(result.GetVariable("record_ms"))
+	samples, _ := strconv.Atoi(result.GetVariable("record_samples"))
+	return &Recording{
+		Path:       o.Path,
+		Duration:   time.Duration(ms) * time.Millisecond,
+		Samples:    samples,
+		Terminator: result.GetVariable("playback_terminator_used"),
+		Result:     result,
+	}, nil
+}
+
+// StopMedia stop the playback or recording in progress with `uuid_break <uuid> all`
+func (c *Call) StopMedia(ctx context.Context) error {
+	resp, err := c.conn.SendCommand(ctx, command.API{Command: "uuid_break", Arguments: c.UUID + " all"})
+	if err != nil {
+		return err
+	}
+	if reply := strings.TrimSpace(resp.GetReply()); strings.HasPrefix(reply, "-ERR") {
+		return fmt.Errorf("%s: %w", reply, ErrCommandFailed)
+	}
+	return nil
+}
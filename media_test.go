@@ -0,0 +1,93 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestMediaArgs(t *testing.T) {
+	tests := []struct {
+		name string
+		args string
+		want string
+	}{
+		{"prompt defaults", PromptOptions{MinDigits: 1, MaxDigits: 4, File: "enter.wav"}.Args(),
+			`1 4 1 5000 # enter.wav silence_stream://250 pagd_digits \d+`},
+		{"prompt full", PromptOptions{
+			MinDigits: 4, MaxDigits: 4, Tries: 3, Timeout: 3 * time.Second, Terminators: "*#",
+			File: "pin.wav", InvalidFile: "invalid.wav", VarName: "pin", Regexp: `\d{4}`,
+			DigitTimeout: 2 * time.Second, TransferOnFailure: "operator XML default",
+		}.Args(), `4 4 3 3000 *# pin.wav invalid.wav pin \d{4} 2000 operator XML default`},
+		{"say", SayOptions{Type: "NUMBER", Text: "123"}.Args(), "en NUMBER pronounced 123"},
+		{"say gender", SayOptions{Module: "es", Type: "NUMBER", Method: "counted", Gender: "FEMININE", Text: "1"}.Args(),
+			"es NUMBER counted FEMININE 1"},
+		{"speak", SpeakOptions{Text: "hello"}.Args(), "hello"},
+		{"speak engine", SpeakOptions{Engine: "flite", Voice: "kal", Text: "hello"}.Args(), "flite|kal|hello"},
+		{"record", RecordOptions{Path: "/tmp/a.wav"}.Args(), "/tmp/a.wav"},
+		{"record limit", RecordOptions{Path: "/tmp/a.wav", MaxDuration: time.Minute}.Args(), "/tmp/a.wav 60"},
+		{"record silence", RecordOptions{Path: "/tmp/a.wav", SilenceThreshold: 200, SilenceHits: 3}.Args(),
+			"/tmp/a.wav 0 200 3"},
+	}
+	for _, tt := range tests {
+		if tt.args != tt.want {
+			t.Errorf("%s: Args() = %q, want %q", tt.name, tt.args, tt.want)
+		}
+	}
+}
+
+func TestCall_Media(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleApp("playback", func(uuid, args string) (string, []string) {
+		if args == "missing.wav" {
+			return "FILE NOT FOUND", nil
+		}
+		return "FILE PLAYED", nil
+	})
+	server.HandleApp("play_and_get_digits", func(uuid, args string) (string, []string) {
+		return "", []string{"variable_pagd_digits", "1234"}
+	})
+	server.HandleApp("record", func(uuid, args string) (string, []string) {
+		return "", []string{"variable_record_ms", "2500", "variable_record_samples", "20000",
+			"variable_playback_terminator_used", "#"}
+	})
+	server.HandleAPI("uuid_break", func(args string) string {
+		if args != "call-1 all" {
+			return "-ERR no such channel\n"
+		}
+		return "+OK\n"
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	if err := client.EnableEvent(ctx, ExecuteEvents...); err != nil {
+		t.Fatal(err)
+	}
+	c := client.Call("call-1")
+
+	if result, err := c.Playback(ctx, "hello.wav"); err != nil || result.Response != "FILE PLAYED" {
+		t.Errorf("Playback() = %#v, %v", result, err)
+	}
+	if _, err := c.Playback(ctx, "missing.wav"); !errors.Is(err, ErrCommandFailed) {
+		t.Errorf("Playback(missing) error = %v", err)
+	}
+	if digits, err := c.PlayAndGetDigits(ctx, PromptOptions{MinDigits: 4, MaxDigits: 4, File: "pin.wav"}); err != nil ||
+		digits != "1234" {
+		t.Errorf("PlayAndGetDigits() = %q, %v", digits, err)
+	}
+	rec, err := c.Record(ctx, RecordOptions{Path: "/tmp/a.wav", MaxDuration: time.Minute})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rec.Path != "/tmp/a.wav" || rec.Duration != 2500*time.Millisecond || rec.Samples != 20000 || rec.Terminator != "#" {
+		t.Errorf("Record() = %#v", rec)
+	}
+	if err := c.StopMedia(ctx); err != nil {
+		t.Errorf("StopMedia() error = %v", err)
+	}
+	if err := client.Call("gone").StopMedia(ctx); !errors.Is(err, ErrCommandFailed) {
+		t.Errorf("StopMedia(gone) error = %v", err)
+	}
+}
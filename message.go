@@ -9,7 +9,6 @@ package esl
 import (
 	"bufio"
 	"bytes"
-	"encoding/json"
 	"fmt"
 	"io"
 	"net/textproto"
@@ -134,35 +133,56 @@ func (m *Message) Parse() error {
 			return fmt.Errorf("%s: %w", string(m.Body)[5:], ErrUnsuccessfulReply)
 		}
 	case "text/event-json":
-		// OK, what is missing here is a way to interpret other JSON types - it expects string only (understandably
-		// because the FS events are generally "string: string") - extract into empty interface and migrate only strings.
-		// i.e. Event CHANNEL_EXECUTE_COMPLETE - "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]
-		var decoded map[string]interface{}
+		// Arrays i.e. Event CHANNEL_EXECUTE_COMPLETE - "variable_DP_MATCH":["a=rtpmap:101 telephone-event/8000","101"]
+		// are kept the way plain events carry them: "ARRAY::a=rtpmap:101 telephone-event/8000|:101"
+		decoded, err := decodeJSONEvent(m.Body)
 
-		if err := json.Unmarshal(m.Body, &decoded); err != nil {
+		if err != nil {
 			return err
 		}
 
 		// Copy back in:
 		for k, v := range decoded {
-			switch v := v.(type) {
-			case string:
-				m.Headers[textproto.CanonicalMIMEHeaderKey(k)] = v
-			case []string:
-				m.Headers[textproto.CanonicalMIMEHeaderKey(k)] = v[0]
-			default:
-				//delete(m.Headers, k)
-				logger.Warnf("Removed non-string property (%s)", k)
+			if k == "_body" {
+				continue
+			}
+			values, ok := jsonHeaderValues(v)
+			if !ok {
+				logger.Warnf("Removed null property (%s)", k)
+				continue
+			}
+			if _, isArray := v.([]interface{}); isArray {
+				m.Headers[textproto.CanonicalMIMEHeaderKey(k)] = "ARRAY::" + strings.Join(values, "|:")
+			} else {
+				m.Headers[textproto.CanonicalMIMEHeaderKey(k)] = values[0]
 			}
 		}
 
-		if v := m.Headers["_body"]; v != "" {
+		if v, ok := decoded["_body"].(string); ok {
 			m.Body = []byte(v)
-			delete(m.Headers, "_body")
 		} else {
 			m.Body = []byte("")
 		}
 
+	case "text/event-xml":
+		event, err := readXMLEvent(m.Body)
+
+		if err != nil {
+			return err
+		}
+		for k, v := range event.Headers {
+			m.Headers[k] = v[0]
+			if strings.Contains(v[0], "%") {
+				m.Headers[k], err = url.QueryUnescape(v[0])
+
+				if err != nil {
+					logger.Error(ErrCouldNotDecode, err)
+					continue
+				}
+			}
+		}
+		m.Body = event.Body
+
 	case "text/event-plain":
 		r := bufio.NewReader(bytes.NewReader(m.Body))
 
@@ -63,6 +63,18 @@ msg7 = `Content-Length: 949
 Content-Type: text/event-json
 
 {"Event-Name":"BACKGROUND_JOB","Core-UUID":"ab97dc06-9369-11ea-8aeb-65fa8de67664","FreeSWITCH-Hostname":"localhost.localdomain","FreeSWITCH-Switchname":"localhost.localdomain","FreeSWITCH-IPv4":"192.168.135.134","FreeSWITCH-IPv6":"::1","Event-Date-Local":"2020-05-11 17:40:25","Event-Date-GMT":"Mon, 11 May 2020 09:40:25 GMT","Event-Date-Timestamp":"1589190025953014","Event-Calling-File":"mod_event_socket.c","Event-Calling-Function":"api_exec","Event-Calling-Line-Number":"1525","Event-Sequence":"651","Job-UUID":"7370aac1-93a2-4155-8c4a-2997edd4176b","Job-Command":"status","Content-Length":"330","_body":"UP 0 years, 0 days, 0 hours, 12 minutes, 41 seconds, 178 milliseconds, 309 microseconds\nFreeSWITCH (Version 1.4.20  64bit) is ready\n0 session(s) since startup\n0 session(s) - peak 0, last 5min 0 \n0 session(s) per Sec out of max 30, peak 0, last 5min 0 \n1000 session(s) max\nmin idle cpu 0.00/99.10\nCurrent Stack Size/Max 240K/8192K\n"}`
+msg8 = `Content-Length: 206
+Content-Type: text/event-xml
+
+<event>
+  <headers>
+    <Event-Name>BACKGROUND_JOB</Event-Name>
+    <Job-UUID>7370aac1-93a2-4155-8c4a-2997edd4176b</Job-UUID>
+    <Content-Length>4</Content-Length>
+  </headers>
+  <body>+OK
+</body>
+</event>`
 )
 
 func Test_newMessage(t *testing.T) {
@@ -83,6 +95,7 @@ func Test_newMessage(t *testing.T) {
 		{"msg5", msg5, args{bufio.NewReader(strings.NewReader(msg5)), true}, false},
 		{"msg6", msg6, args{bufio.NewReader(strings.NewReader(msg6)), true}, false},
 		{"msg7", msg7, args{bufio.NewReader(strings.NewReader(msg7)), true}, false},
+		{"msg8", msg8, args{bufio.NewReader(strings.NewReader(msg8)), true}, false},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
@@ -98,3 +111,23 @@ func Test_newMessage(t *testing.T) {
 		})
 	}
 }
+
+func Test_newMessageJSONValues(t *testing.T) {
+	msg := `Content-Length: 105
+Content-Type: text/event-json
+
+{"Event-Name":"CHANNEL_EXECUTE_COMPLETE","variable_DP_MATCH":["1000","1"],"variable_loops":3,"_body":"x"}`
+	got, err := newMessage(bufio.NewReader(strings.NewReader(msg)), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := got.GetHeader("Variable_dp_match"); v != "ARRAY::1000|:1" {
+		t.Errorf("Variable_DP_MATCH = %q", v)
+	}
+	if v := got.GetHeader("Variable_loops"); v != "3" {
+		t.Errorf("Variable_loops = %q", v)
+	}
+	if string(got.Body) != "x" {
+		t.Errorf("Body = %q", got.Body)
+	}
+}
@@ -0,0 +1,138 @@
+package esl
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/zhifeichen/log"
+)
+
+// Option configures a Client created by Dial
+type Option func(c *Client)
+
+// WithPassword authenticate with `auth password`
+func WithPassword(passwd string) Option {
+	return func(c *Client) {
+		c.User = ""
+		c.Passwd = passwd
+	}
+}
+
+// WithUserAuth authenticate with `userauth user:password`
+func WithUserAuth(user, passwd string) Option {
+	return func(c *Client) {
+		c.User = user
+		c.Passwd = passwd
+	}
+}
+
+// WithTLS dial over TLS, set Certificates in config for mutual TLS
+func WithTLS(config *tls.Config) Option {
+	return func(c *Client) {
+		c.TLSConfig = config
+	}
+}
+
+// WithDialTimeout tcp (and tls handshake) dial timeout
+func WithDialTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.DialTimeout = d
+	}
+}
+
+// WithTimeout timeout waiting for auth/request and replies while connecting, default 5s
+func WithTimeout(d time.Duration) Option {
+	return func(c *Client) {
+		c.handshake = d
+	}
+}
+
+// WithKeepAlive tcp keepalive period, negative disables it
+func WithKeepAlive(d time.Duration) Option {
+	return func(c *Client) {
+		c.KeepAlive = d
+	}
+}
+
+// WithDispatch run event handlers on a worker pool, see DispatchOptions
+func WithDispatch(o DispatchOptions) Option {
+	return func(c *Client) {
+		c.Dispatch = o
+	}
+}
+
+// WithEventFormat event format plain (default), json or xml
+func WithEventFormat(format string) Option {
+	return func(c *Client) {
+		c.eventFormat = format
+	}
+}
+
+// WithEvents events subscribed on connect, default BACKGROUND_JOB
+func WithEvents(events ...string) Option {
+	return func(c *Client) {
+		c.events = strings.Join(events, " ")
+	}
+}
+
+// WithSendConns number of connections of the send Pool, used by SendCommand2
+func WithSendConns(n int) Option {
+	return func(c *Client) {
+		if n < 0 {
+			n = 0
+		}
+		c.sendConnCnt = n
+	}
+}
+
+// WithPool send Pool of o.Size connections, with its in-flight limit and health checks
+func WithPool(o PoolOptions) Option {
+	return func(c *Client) {
+		c.poolOpts = o
+		WithSendConns(o.Size)(c)
+	}
+}
+
+// WithReconnectPolicy backoff between reconnect attempts
+func WithReconnectPolicy(p ReconnectPolicy) Option {
+	return func(c *Client) {
+		c.Reconnect = p
+	}
+}
+
+// WithLogger log the client and its connections to l instead of the package logger set by EnableLog
+func WithLogger(l *log.Logger) Option {
+	return func(c *Client) {
+		c.log = l
+	}
+}
+
+// Dial connect to addr (host:port), authenticate and subscribe events. The returned client is ready to
+// use and reconnects until Stop. ctx bounds the first connection only.
+func Dial(ctx context.Context, addr string, opts ...Option) (*Client, error) {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, ErrInvalidServerAddr
+	}
+	c := &Client{
+		Proto:       "tcp",
+		Addr:        addr,
+		chnClosed:   make(chan struct{}, 1),
+		eventFormat: EventFormatPlain,
+		events:      "BACKGROUND_JOB",
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel func()
+		ctx, cancel = context.WithTimeout(ctx, 2*c.timeout())
+		defer cancel()
+	}
+	if err := c.start(ctx, c.eventFormat, c.events); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
@@ -0,0 +1,138 @@
+package esl
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+	"github.com/zhifeichen/log"
+)
+
+func TestDial(t *testing.T) {
+	server := newTestServer(t)
+	server.Users["1000@default"] = "secret"
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, server.Addr(),
+		WithUserAuth("1000@default", "secret"),
+		WithEventFormat(EventFormatJSON),
+		WithEvents("BACKGROUND_JOB", "CHANNEL_ANSWER"),
+		WithSendConns(1),
+		WithDialTimeout(time.Second),
+		WithTimeout(2*time.Second),
+		WithKeepAlive(-1),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+
+	if client.State() != StateConnected {
+		t.Errorf("State() = %s", client.State())
+	}
+	for i := 0; i < 2; i++ {
+		conn, err := server.WaitConn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.WaitCommand(ctx, "userauth 1000@default:secret"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.WaitCommand(ctx, "event json "); err != nil {
+			t.Fatal(err)
+		}
+	}
+	resp, err := client.SendCommand(ctx, command.API{Command: "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resp.Body) == 0 {
+		t.Error("empty status")
+	}
+}
+
+// syncBuffer buffer written by the loops of a connection
+type syncBuffer struct {
+	sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.Lock()
+	defer b.Unlock()
+	return b.buf.String()
+}
+
+func TestDial_Logger(t *testing.T) {
+	server := newTestServer(t)
+	out := &syncBuffer{}
+	l := log.New(log.NewOptions(log.Filename(filepath.Join(t.TempDir(), "esl.log")), log.Writers(out)))
+	global := logger
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, server.Addr(), WithPassword(esltest.DefaultPassword), WithLogger(l))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// another client keeps the package logger
+	other, err := Dial(ctx, server.Addr(), WithPassword(esltest.DefaultPassword))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Stop()
+	client.Stop()
+
+	if logger != global {
+		t.Error("WithLogger replaced the package logger")
+	}
+	if other.logger() != global {
+		t.Error("client without WithLogger does not use the package logger")
+	}
+	if s := out.String(); !strings.Contains(s, "connect to "+server.Addr()+" success") {
+		t.Errorf("client log = %q", s)
+	}
+}
+
+func TestDial_Errors(t *testing.T) {
+	server := newTestServer(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := Dial(ctx, "no-port"); err != ErrInvalidServerAddr {
+		t.Errorf("Dial(no-port) error = %v", err)
+	}
+	if _, err := Dial(ctx, server.Addr(), WithPassword("wrong")); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Dial() with bad password error = %v", err)
+	}
+	if _, err := Dial(ctx, server.Addr(), WithEventFormat("yaml")); err != ErrUnsupportedEventFormat {
+		t.Errorf("Dial() with bad format error = %v", err)
+	}
+
+	// accepts but never sends auth/request
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	short, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if _, err := Dial(short, l.Addr().String(), WithPassword("ClueCon")); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Dial() silent server error = %v", err)
+	}
+}
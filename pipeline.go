@@ -0,0 +1,129 @@
+package esl
+
+import (
+	"context"
+	"sync"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// request command waiting for its command/reply or api/response
+type request struct {
+	cmd   command.Command
+	jobid string
+	// reply buffered so the receive loop never waits, closed when the connection closes first
+	reply chan *RawResponse
+	// abandoned the caller is gone, its reply is discarded
+	abandoned bool
+}
+
+func newRequest(cmd command.Command, jobid string) *request {
+	return &request{
+		cmd:   cmd,
+		jobid: jobid,
+		reply: make(chan *RawResponse, 1),
+	}
+}
+
+// pendingRequests FIFO of the commands written and not replied yet, FreeSWITCH replies in order
+type pendingRequests struct {
+	sync.Mutex
+	queue  []*request
+	closed bool
+}
+
+func (p *pendingRequests) push(req *request) bool {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.closed {
+		return false
+	}
+	p.queue = append(p.queue, req)
+	return true
+}
+
+// pop oldest request, nil when nothing is pending
+func (p *pendingRequests) pop() *request {
+	p.Lock()
+	defer p.Unlock()
+
+	if len(p.queue) == 0 {
+		return nil
+	}
+	req := p.queue[0]
+	p.queue[0] = nil
+	p.queue = p.queue[1:]
+	return req
+}
+
+// close fail the pending requests, no reply will come
+func (p *pendingRequests) close() {
+	p.Lock()
+	defer p.Unlock()
+
+	p.closed = true
+	for _, req := range p.queue {
+		close(req.reply)
+	}
+	p.queue = nil
+}
+
+// writeRequest queue req and write its command, the queue order is the wire order. A failed write
+// closes the connection: part of the command may be on the wire and the replies would no longer
+// match the queue.
+func (c *Connection) writeRequest(ctx context.Context, req *request) error {
+	c.writeLock.Lock()
+	sendString := req.cmd.BuildMessage()
+	c.logger().Debugf("send command: %s\n", sendString)
+	if c.conn == nil || !c.pending.push(req) {
+		c.writeLock.Unlock()
+		c.logger().Errorf("send command %s error: Connection closed", sendString)
+		return ErrConnClosed
+	}
+
+	// zero deadline clears the one set by a previous command
+	deadline, _ := ctx.Deadline()
+	c.conn.SetWriteDeadline(deadline)
+	_, err := c.conn.Write([]byte(sendString + EndOfMessage))
+	c.writeLock.Unlock()
+	if err != nil {
+		c.logger().Errorf("send command %s error: %s, closing connection\n", sendString, err.Error())
+		c.Close()
+		return err
+	}
+	return nil
+}
+
+// abandon give up waiting for the reply of req
+func (c *Connection) abandon(req *request) {
+	c.pending.Lock()
+	req.abandoned = true
+	c.pending.Unlock()
+
+	c.removeJob(req.jobid)
+}
+
+// deliverReply hand a reply to the oldest pending request. The server state changed whether or not
+// the caller still waits, so subscriptions and jobs are updated first.
+func (c *Connection) deliverReply(response *RawResponse) {
+	req := c.pending.pop()
+	if req == nil {
+		c.logger().Warnf("reply without pending command: %s\n", response.GetReply())
+		return
+	}
+	c.settleJob(req.jobid, response)
+	if response.GetHeader("Content-Type") == TypeReply && response.IsOk() && c.subscriptions != nil {
+		c.subscriptions.record(req.cmd)
+	}
+
+	c.pending.Lock()
+	abandoned := req.abandoned
+	c.pending.Unlock()
+	if abandoned {
+		c.removeJob(req.jobid)
+		c.logger().Debugf("discard reply of abandoned command %s: %s\n", req.cmd.BuildMessage(), response.GetReply())
+		return
+	}
+	req.reply <- response
+}
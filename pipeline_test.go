@@ -0,0 +1,105 @@
+package esl
+
+import (
+	"context"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+func TestConnection_Pipelining(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleAPI("echo", func(args string) string {
+		return args
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			arg := strconv.Itoa(i)
+			resp, err := client.SendCommand(ctx, command.API{Command: "echo", Arguments: arg})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if body := strings.TrimSpace(string(resp.Body)); body != arg {
+				t.Errorf("echo %s got %q", arg, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+}
+
+func TestConnection_StalledWrite(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	conn := newConnect(context.Background(), client, false)
+	defer conn.Close()
+	go conn.receiveLoop()
+
+	// the server reads the start of the command, then stalls
+	go func() {
+		buf := make([]byte, 16)
+		io.ReadFull(server, buf)
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	cmd := command.API{Command: "echo", Arguments: strings.Repeat("x", 1024)}
+	if _, err := conn.SendCommand(ctx, cmd); err == nil {
+		t.Fatal("SendCommand() on a stalled connection succeeded")
+	}
+
+	// the half written command would take the reply of the next one, the connection is unusable
+	ctx, cancel = context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if _, err := conn.SendCommand(ctx, command.API{Command: "status"}); err != ErrConnClosed {
+		t.Errorf("SendCommand() after a failed write error = %v, want %v", err, ErrConnClosed)
+	}
+}
+
+func TestConnection_AbandonedReply(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleAPI("slow", func(args string) string {
+		time.Sleep(200 * time.Millisecond)
+		return "slow"
+	})
+	server.HandleAPI("echo", func(args string) string {
+		return args
+	})
+	client := newTestClient(t, server, "plain", 0)
+
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := client.SendCommand(short, command.API{Command: "slow"}); err != context.DeadlineExceeded {
+		t.Fatalf("SendCommand() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+
+	// the late reply of slow is discarded, not handed to the next caller
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	resp, err := client.SendCommand(ctx, command.API{Command: "echo", Arguments: "next"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body := strings.TrimSpace(string(resp.Body)); body != "next" {
+		t.Errorf("echo next got %q", body)
+	}
+	resp, err = client.SendCommand(ctx, command.Event{Format: "plain", Listen: []string{"CHANNEL_CREATE"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !resp.IsOk() || !strings.Contains(resp.GetReply(), "event listener enabled") {
+		t.Errorf("event reply = %q", resp.GetReply())
+	}
+}
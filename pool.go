@@ -0,0 +1,288 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// PoolOptions options of a Pool
+type PoolOptions struct {
+	// Size number of connections, default 1
+	Size int
+	// MaxInFlight commands waiting for their reply per connection, unlimited when zero.
+	// Do waits for a free connection when all are at the limit.
+	MaxInFlight int
+	// HealthCheckInterval period of the `api status` check of idle and busy connections alike,
+	// default 30s, negative disables it
+	HealthCheckInterval time.Duration
+	// HealthCheckTimeout a connection failing to answer in time is closed and redialed, default 5s
+	HealthCheckTimeout time.Duration
+	// Reconnect backoff between dial attempts of a connection, zero fields use DefaultReconnectPolicy
+	Reconnect ReconnectPolicy
+}
+
+// PoolDialer open an authenticated connection with its receive and event loops running
+type PoolDialer func(ctx context.Context) (*Connection, error)
+
+// Pool connections sending commands in parallel. Each connection is redialed with backoff when it is
+// lost or fails its health check, Do sends on the least busy healthy connection.
+type Pool struct {
+	opts PoolOptions
+	dial PoolDialer
+	// report state changes of connection i, may be nil
+	report func(i int, state ClientState, err error)
+
+	ctx      context.Context
+	cancel   func()
+	mtx      sync.Mutex
+	conns    []*poolConn
+	next     int
+	notify   chan struct{}
+	closed   bool
+	inFlight sync.WaitGroup
+	done     sync.WaitGroup
+}
+
+type poolConn struct {
+	conn     *Connection
+	inFlight int
+}
+
+// NewPool start dialing the connections of the pool, Do waits until one is up
+func NewPool(dial PoolDialer, opts PoolOptions) *Pool {
+	return newPool(dial, opts, nil)
+}
+
+func newPool(dial PoolDialer, opts PoolOptions, report func(i int, state ClientState, err error)) *Pool {
+	if opts.Size <= 0 {
+		opts.Size = 1
+	}
+	if opts.HealthCheckInterval == 0 {
+		opts.HealthCheckInterval = 30 * time.Second
+	}
+	if opts.HealthCheckTimeout <= 0 {
+		opts.HealthCheckTimeout = 5 * time.Second
+	}
+	p := &Pool{
+		opts:   opts,
+		dial:   dial,
+		report: report,
+		conns:  make([]*poolConn, opts.Size),
+		notify: make(chan struct{}),
+	}
+	p.ctx, p.cancel = context.WithCancel(context.Background())
+	p.done.Add(opts.Size)
+	for i := range p.conns {
+		go p.keep(i)
+	}
+	return p
+}
+
+// Do send cmd on the least busy connection and wait for its reply. fn is the callback of a bgapi
+// command, see Connection.SendCommand.
+func (p *Pool) Do(ctx context.Context, cmd command.Command, fn ...EventHandler) (*RawResponse, error) {
+	pc, err := p.acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer p.release(pc)
+
+	return pc.conn.SendCommand(ctx, cmd, fn...)
+}
+
+// acquire wait for a healthy connection below MaxInFlight
+func (p *Pool) acquire(ctx context.Context) (*poolConn, error) {
+	for {
+		p.mtx.Lock()
+		if p.closed {
+			p.mtx.Unlock()
+			return nil, ErrPoolClosed
+		}
+		if pc := p.leastBusy(); pc != nil {
+			pc.inFlight++
+			p.inFlight.Add(1)
+			p.mtx.Unlock()
+			return pc, nil
+		}
+		notify := p.notify
+		p.mtx.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// leastBusy healthy connection with the fewest commands in flight, ties are rotated. p.mtx must be held.
+func (p *Pool) leastBusy() *poolConn {
+	var best *poolConn
+	n := len(p.conns)
+	for i := 0; i < n; i++ {
+		pc := p.conns[(p.next+i)%n]
+		if pc == nil || (p.opts.MaxInFlight > 0 && pc.inFlight >= p.opts.MaxInFlight) {
+			continue
+		}
+		if best == nil || pc.inFlight < best.inFlight {
+			best = pc
+		}
+	}
+	p.next++
+	return best
+}
+
+func (p *Pool) release(pc *poolConn) {
+	p.mtx.Lock()
+	pc.inFlight--
+	p.broadcast()
+	p.mtx.Unlock()
+	p.inFlight.Done()
+}
+
+// broadcast wake up the callers waiting in acquire. p.mtx must be held.
+func (p *Pool) broadcast() {
+	close(p.notify)
+	p.notify = make(chan struct{})
+}
+
+func (p *Pool) setConn(i int, pc *poolConn) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.conns[i] = pc
+	p.broadcast()
+}
+
+func (p *Pool) setState(i int, state ClientState, err error) {
+	if p.report != nil {
+		p.report(i, state, err)
+	}
+}
+
+// keep connection i up until the pool is closed
+func (p *Pool) keep(i int) {
+	defer p.done.Done()
+
+	attempt := 0
+	for p.ctx.Err() == nil {
+		p.setState(i, StateConnecting, nil)
+		conn, err := p.dial(p.ctx)
+		if err == nil {
+			attempt = 0
+			pc := &poolConn{conn: conn}
+			p.setConn(i, pc)
+			p.setState(i, StateConnected, nil)
+			err = p.watch(conn)
+			p.setConn(i, nil)
+			conn.Close()
+		}
+		p.setState(i, StateDisconnected, err)
+		if p.ctx.Err() != nil {
+			return
+		}
+
+		delay := p.opts.Reconnect.Delay(attempt)
+		attempt++
+		logger.Warnf("pool connection %d lost: %v, reconnect in %s\n", i, err, delay)
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return
+		}
+	}
+}
+
+// watch wait until conn is lost or fails a health check
+func (p *Pool) watch(conn *Connection) error {
+	disconnectChn := conn.responseChn(TypeDisconnect)
+	var tick <-chan time.Time
+	if p.opts.HealthCheckInterval > 0 {
+		ticker := time.NewTicker(p.opts.HealthCheckInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-p.ctx.Done():
+			return ErrPoolClosed
+		case <-conn.runningContext.Done():
+			return ErrConnClosed
+		case <-disconnectChn:
+			return ErrConnClosed
+		case <-tick:
+			if err := p.check(conn); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// check send `api status` and wait for the reply
+func (p *Pool) check(conn *Connection) error {
+	ctx, cancel := context.WithTimeout(p.ctx, p.opts.HealthCheckTimeout)
+	defer cancel()
+
+	if _, err := conn.SendCommand(ctx, command.API{Command: "status"}); err != nil {
+		return fmt.Errorf("health check: %w", err)
+	}
+	return nil
+}
+
+// PoolStats load of the pool
+type PoolStats struct {
+	// Connected healthy connections
+	Connected int
+	// InFlight commands waiting for their reply
+	InFlight int
+}
+
+// Stats current load of the pool
+func (p *Pool) Stats() PoolStats {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	var stats PoolStats
+	for _, pc := range p.conns {
+		if pc != nil {
+			stats.Connected++
+			stats.InFlight += pc.inFlight
+		}
+	}
+	return stats
+}
+
+// Drain stop accepting commands, wait for the ones in flight and close the connections. When ctx is done
+// first the connections are closed anyway and ctx.Err() is returned.
+func (p *Pool) Drain(ctx context.Context) error {
+	p.mtx.Lock()
+	p.closed = true
+	p.broadcast()
+	p.mtx.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.inFlight.Wait()
+		close(drained)
+	}()
+	var err error
+	select {
+	case <-drained:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	p.cancel()
+	p.done.Wait()
+	return err
+}
+
+// Close close the connections, commands in flight fail
+func (p *Pool) Close() {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	p.Drain(ctx)
+}
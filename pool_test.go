@@ -0,0 +1,187 @@
+package esl
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+// waitPool wait until connected connections of p are up
+func waitPool(t *testing.T, p *Pool, connected int) {
+	t.Helper()
+	for i := 0; i < 300 && p.Stats().Connected != connected; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if stats := p.Stats(); stats.Connected != connected {
+		t.Fatalf("pool stats = %+v, want %d connected", stats, connected)
+	}
+}
+
+func TestPool_Do(t *testing.T) {
+	server := newTestServer(t)
+	release := make(chan struct{})
+	server.HandleAPI("block", func(args string) string {
+		<-release
+		return "done " + args
+	})
+	client := newTestClient(t, server, "plain", 0)
+	pool := NewPool(client.DialConn, PoolOptions{Size: 2, MaxInFlight: 1})
+	defer pool.Close()
+	waitPool(t, pool, 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	var wg sync.WaitGroup
+	for _, arg := range []string{"a", "b"} {
+		wg.Add(1)
+		go func(arg string) {
+			defer wg.Done()
+			resp, err := pool.Do(ctx, command.API{Command: "block", Arguments: arg})
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			if body := strings.TrimSpace(string(resp.Body)); body != "done "+arg {
+				t.Errorf("block %s got %q", arg, body)
+			}
+		}(arg)
+	}
+	// one command per connection, the third caller waits for a free one
+	for i := 0; i < 300 && pool.Stats().InFlight != 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	short, cancelShort := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancelShort()
+	if _, err := pool.Do(short, command.API{Command: "status"}); err != context.DeadlineExceeded {
+		t.Errorf("Do() on a busy pool error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	busy := 0
+	for _, c := range server.Conns() {
+		for _, cmd := range c.Commands() {
+			if strings.HasPrefix(cmd.String(), "api block") {
+				busy++
+			}
+		}
+	}
+	close(release)
+	wg.Wait()
+	if busy != 2 {
+		t.Errorf("%d connections got api block, want 2", busy)
+	}
+
+	resp, err := pool.Do(ctx, command.API{Command: "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(resp.Body), "UP") {
+		t.Errorf("api status = %q", resp.Body)
+	}
+}
+
+func TestPool_Reconnect(t *testing.T) {
+	server := newTestServer(t)
+	client := newTestClient(t, server, "plain", 0)
+	main, err := server.WaitConn(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := NewPool(client.DialConn, PoolOptions{
+		Size:                2,
+		HealthCheckInterval: 20 * time.Millisecond,
+		Reconnect:           ReconnectPolicy{InitialDelay: 10 * time.Millisecond},
+	})
+	defer pool.Close()
+	waitPool(t, pool, 2)
+
+	for _, c := range server.Conns() {
+		if c != main {
+			c.Disconnect(false)
+		}
+	}
+	waitPool(t, pool, 0)
+	waitPool(t, pool, 2)
+	// health checks keep flowing on the new connections
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	for _, c := range server.Conns() {
+		if c == main {
+			continue
+		}
+		if _, err := c.WaitCommand(ctx, "api status"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestPool_Drain(t *testing.T) {
+	server := newTestServer(t)
+	server.HandleAPI("slow", func(args string) string {
+		time.Sleep(100 * time.Millisecond)
+		return "slow"
+	})
+	client := newTestClient(t, server, "plain", 0)
+	pool := NewPool(client.DialConn, PoolOptions{Size: 1})
+	waitPool(t, pool, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	done := make(chan error, 1)
+	go func() {
+		_, err := pool.Do(ctx, command.API{Command: "slow"})
+		done <- err
+	}()
+	for i := 0; i < 300 && pool.Stats().InFlight != 1; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	if err := pool.Drain(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := <-done; err != nil {
+		t.Errorf("Do() in flight during Drain error = %v", err)
+	}
+	if _, err := pool.Do(ctx, command.API{Command: "status"}); err != ErrPoolClosed {
+		t.Errorf("Do() after Drain error = %v, want %v", err, ErrPoolClosed)
+	}
+	if stats := pool.Stats(); stats.Connected != 0 {
+		t.Errorf("stats after Drain = %+v", stats)
+	}
+}
+
+func TestClient_Pool(t *testing.T) {
+	server := newTestServer(t)
+	client, err := Dial(context.Background(), server.Addr(),
+		WithPassword(esltest.DefaultPassword),
+		WithPool(PoolOptions{Size: 2, MaxInFlight: 4}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitPool(t, client.Pool(), 2)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	got := make(chan *Event, 1)
+	client.SendCommand2(ctx, command.API{Command: "status"}, func(e *Event) {
+		got <- e
+	})
+	select {
+	case e := <-got:
+		if !strings.HasPrefix(string(e.Body), "UP") {
+			t.Errorf("SendCommand2 reply body = %q", e.Body)
+		}
+	case <-ctx.Done():
+		t.Fatal(ctx.Err())
+	}
+
+	client.Stop()
+	if client.Pool() != nil {
+		t.Error("Pool() after Stop is not nil")
+	}
+	// no panic after Stop
+	client.SendCommand2(ctx, command.API{Command: "status"})
+}
@@ -0,0 +1,50 @@
+package esl
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// ReconnectPolicy exponential backoff between reconnect attempts
+type ReconnectPolicy struct {
+	// InitialDelay delay before the first retry
+	InitialDelay time.Duration
+	// MaxDelay upper bound of the delay
+	MaxDelay time.Duration
+	// Multiplier growth factor of the delay per attempt
+	Multiplier float64
+	// Jitter randomizes the delay by +/- Jitter fraction, 0.2 means +/- 20%
+	Jitter float64
+}
+
+// DefaultReconnectPolicy used for zero fields of Client.Reconnect
+var DefaultReconnectPolicy = ReconnectPolicy{
+	InitialDelay: 500 * time.Millisecond,
+	MaxDelay:     30 * time.Second,
+	Multiplier:   2,
+	Jitter:       0.2,
+}
+
+// Delay delay before retry attempt, attempt starts from 0
+func (p ReconnectPolicy) Delay(attempt int) time.Duration {
+	if p.InitialDelay <= 0 {
+		p.InitialDelay = DefaultReconnectPolicy.InitialDelay
+	}
+	if p.MaxDelay <= 0 {
+		p.MaxDelay = DefaultReconnectPolicy.MaxDelay
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = DefaultReconnectPolicy.Multiplier
+	}
+	if p.Jitter < 0 || p.Jitter > 1 {
+		p.Jitter = DefaultReconnectPolicy.Jitter
+	}
+
+	delay := float64(p.InitialDelay) * math.Pow(p.Multiplier, float64(attempt))
+	if delay > float64(p.MaxDelay) {
+		delay = float64(p.MaxDelay)
+	}
+	delay += delay * p.Jitter * (rand.Float64()*2 - 1)
+	return time.Duration(delay)
+}
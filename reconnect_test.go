@@ -0,0 +1,317 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestReconnectPolicy_Delay(t *testing.T) {
+	p := ReconnectPolicy{InitialDelay: 100 * time.Millisecond, MaxDelay: time.Second, Multiplier: 2, Jitter: 0.1}
+	tests := []struct {
+		attempt int
+		want    time.Duration
+	}{
+		{0, 100 * time.Millisecond},
+		{1, 200 * time.Millisecond},
+		{3, 800 * time.Millisecond},
+		{10, time.Second},
+	}
+	for _, tt := range tests {
+		got := p.Delay(tt.attempt)
+		if got < tt.want*9/10 || got > tt.want*11/10 {
+			t.Errorf("Delay(%d) = %s, want %s +/- 10%%", tt.attempt, got, tt.want)
+		}
+	}
+	if got := (ReconnectPolicy{Jitter: -1}).Delay(0); got < 400*time.Millisecond || got > 600*time.Millisecond {
+		t.Errorf("default Delay(0) = %s", got)
+	}
+}
+
+func TestSubscriptions_Commands(t *testing.T) {
+	s := newSubscriptions()
+	s.record(command.Event{Format: "json", Listen: []string{"BACKGROUND_JOB CHANNEL_CREATE"}})
+	s.record(command.Event{Format: "json", Listen: []string{"CHANNEL_ANSWER", "CHANNEL_CREATE"}})
+	s.record(command.Event{Ignore: true, Format: "json", Listen: []string{"CHANNEL_CREATE"}})
+	s.record(command.Filter{EventHeader: "Unique-ID", FilterValue: "a"})
+	s.record(&command.Filter{EventHeader: "Unique-ID", FilterValue: "b"})
+	s.record(command.Filter{EventHeader: "Event-Name", FilterValue: "HEARTBEAT"})
+	s.record(command.Filter{Delete: true, EventHeader: "Unique-ID", FilterValue: "a"})
+	s.record(command.Filter{Delete: true, EventHeader: "Event-Name"})
+	s.record(command.DivertEvents{Enabled: true})
+	s.record(command.Log{Enabled: true, Level: 7})
+	s.record(command.API{Command: "status"})
+
+	want := []string{
+		"event json BACKGROUND_JOB CHANNEL_ANSWER",
+		"divert_events on",
+		"filter Unique-ID b",
+		"log 7",
+	}
+	cmds := s.commands()
+	if len(cmds) != len(want) {
+		t.Fatalf("commands() = %#v", cmds)
+	}
+	for i, cmd := range cmds {
+		if cmd.BuildMessage() != want[i] {
+			t.Errorf("commands()[%d] = %q, want %q", i, cmd.BuildMessage(), want[i])
+		}
+	}
+
+	s.record(command.Log{})
+	s.record(command.DisableEvents{})
+	if len(s.commands()) != 2 {
+		t.Errorf("commands() after nolog/noevents = %#v", s.commands())
+	}
+}
+
+func TestSubscriptions_Custom(t *testing.T) {
+	s := newSubscriptions()
+	s.record(command.Event{Format: "plain", Listen: []string{"BACKGROUND_JOB"}})
+	s.record(command.Event{Format: "plain", Listen: []string{"CUSTOM", "conference::maintenance"}})
+	s.record(command.Event{Format: "plain", Listen: []string{"CHANNEL_CREATE CHANNEL_ANSWER"}})
+	s.record(command.Event{Format: "plain", Listen: []string{"CUSTOM sofia::register sofia::expire"}})
+	s.record(command.Event{Ignore: true, Format: "plain", Listen: []string{"CUSTOM sofia::expire"}})
+
+	want := "event plain BACKGROUND_JOB CHANNEL_CREATE CHANNEL_ANSWER CUSTOM conference::maintenance sofia::register"
+	if cmds := s.commands(); len(cmds) != 1 || cmds[0].BuildMessage() != want {
+		t.Errorf("commands() = %#v, want %q", cmds, want)
+	}
+
+	s.record(command.Event{Ignore: true, Format: "plain", Listen: []string{"CUSTOM"}})
+	want = "event plain BACKGROUND_JOB CHANNEL_CREATE CHANNEL_ANSWER"
+	if cmds := s.commands(); len(cmds) != 1 || cmds[0].BuildMessage() != want {
+		t.Errorf("commands() after nixevent CUSTOM = %#v, want %q", cmds, want)
+	}
+}
+
+func TestClient_ReconnectReplay(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Reconnect = ReconnectPolicy{InitialDelay: 10 * time.Millisecond}
+	states := make(chan ClientState, 16)
+	client.OnStateChange(func(from, to ClientState) {
+		states <- to
+	})
+	if err := client.Start("json", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	// main connection and the send connection
+	for i := 0; i < 2; i++ {
+		conn, err := server.WaitConn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.WaitCommand(ctx, "event json BACKGROUND_JOB"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, cmd := range []command.Command{
+		command.Filter{EventHeader: "Unique-ID", FilterValue: "abc"},
+		command.DivertEvents{Enabled: true},
+		command.Log{Enabled: true, Level: 4},
+	} {
+		if _, err := client.SendCommand(ctx, cmd); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := client.EnableEvent(ctx, "CUSTOM", "conference::maintenance"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.EnableEvent(ctx, "CHANNEL_ANSWER"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, c := range server.Conns() {
+		c.Disconnect(false)
+	}
+
+	want := []string{
+		"event json BACKGROUND_JOB CHANNEL_ANSWER CUSTOM conference::maintenance",
+		"divert_events on",
+		"filter Unique-ID abc",
+		"log 4",
+	}
+	for _, line := range want {
+		if err := waitLiveCommand(ctx, server, line); err != nil {
+			t.Fatalf("%q not replayed: %s", line, err)
+		}
+	}
+
+	var reconnecting bool
+	for {
+		select {
+		case s := <-states:
+			reconnecting = reconnecting || s == StateReconnecting
+			if reconnecting && s == StateConnected {
+				return
+			}
+		case <-ctx.Done():
+			t.Fatal("no reconnecting -> connected transition")
+		}
+	}
+}
+
+// waitLiveCommand wait until any open connection receives a command starting with prefix
+func waitLiveCommand(ctx context.Context, server *esltest.Server, prefix string) error {
+	for {
+		for _, c := range server.Conns() {
+			pollCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+			_, err := c.WaitCommand(pollCtx, prefix)
+			cancel()
+			if err == nil {
+				select {
+				case <-c.Done():
+				default:
+					return nil
+				}
+			}
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+	}
+}
+
+func TestClient_ReconnectAfterReadError(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Reconnect = ReconnectPolicy{InitialDelay: 10 * time.Millisecond}
+	states := make(chan ClientState, 16)
+	client.OnStateChange(func(from, to ClientState) {
+		states <- to
+	})
+	if err := client.Start("plain", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	reconnected := func() {
+		var reconnecting bool
+		for {
+			select {
+			case s := <-states:
+				reconnecting = reconnecting || s == StateReconnecting
+				if reconnecting && s == StateConnected {
+					return
+				}
+			case <-ctx.Done():
+				t.Fatal("no reconnecting -> connected transition")
+			}
+		}
+	}
+	conn, err := server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendCommand(ctx, command.Log{Enabled: true, Level: 7}); err != nil {
+		t.Fatal(err)
+	}
+	// frames nobody reads are skipped
+	conn.SendLog(7, "switch_core_session.c:1234 New Channel\n")
+	if _, err := client.SendCommand(ctx, command.API{Command: "status"}); err != nil {
+		t.Fatalf("SendCommand() after log/data = %v", err)
+	}
+
+	// any read error is a lost connection, not only EOF
+	conn.Write("Content-Type: api/response\nContent-Length: x\n\n")
+	reconnected()
+	next, err := server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := client.SendCommand(ctx, command.API{Command: "status"}); err != nil {
+		t.Fatalf("SendCommand() after reconnect = %v", err)
+	}
+
+	// a dropped socket
+	next.Close()
+	reconnected()
+}
+
+func TestClient_AuthFailure(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), "wrong", 3, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.Start("plain", "BACKGROUND_JOB")
+	if !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Start() error = %v, want %v", err, ErrInvalidPassword)
+	}
+	client.Stop()
+}
+
+func TestClient_SendDuringReconnect(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Reconnect = ReconnectPolicy{InitialDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}
+	if err := client.Start("plain", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				// errors are expected while the connection goes down, a crash is not
+				client.SendCommand(ctx, command.API{Command: "status"})
+				if sub, err := client.FilterChannel(ctx, "call-1", func(*Event) {}); err == nil {
+					sub.Unsubscribe()
+				}
+			}
+		}()
+	}
+	for i := 0; i < 5; i++ {
+		conn, err := server.WaitConn(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := conn.WaitCommand(ctx, "api status"); err != nil {
+			t.Fatal(err)
+		}
+		conn.Disconnect(false)
+	}
+	close(stop)
+	wg.Wait()
+
+	for {
+		if _, err := client.SendCommand(ctx, command.API{Command: "status"}); err == nil {
+			return
+		} else if ctx.Err() != nil {
+			t.Fatalf("no reply after reconnect: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
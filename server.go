@@ -2,55 +2,253 @@ package esl
 
 import (
 	"context"
+	"crypto/tls"
 	"net"
+	"sync"
 )
 
 // OutboundHandler connection handler
 type OutboundHandler func(ctx context.Context, conn *Connection)
 
-type outboundServer struct {
-	listener net.Listener
-	ctx      context.Context
-	stop     context.CancelFunc
+// OutboundServer event socket server FreeSWITCH connects to with the `socket` application.
+// Several servers can run in one process.
+type OutboundServer struct {
+	// Addr listen address of ListenAndServe, e.g. ":8084"
+	Addr    string
+	Handler OutboundHandler
+	// TLSConfig listen over TLS in ListenAndServe when set, see ListenAndServeTLS for mutual TLS
+	TLSConfig *tls.Config
+	// Dispatch run event handlers of each connection on a worker pool when Dispatch.Workers is set
+	Dispatch DispatchOptions
+
+	mtx       sync.Mutex
+	ctx       context.Context
+	stop      context.CancelFunc
+	listeners map[net.Listener]struct{}
+	conns     map[*Connection]struct{}
+	wg        sync.WaitGroup
+	closed    bool
 }
 
-var server outboundServer
+// NewOutboundServer create outbound server listening on addr
+func NewOutboundServer(addr string, handler OutboundHandler) *OutboundServer {
+	return &OutboundServer{
+		Addr:    addr,
+		Handler: handler,
+	}
+}
 
-// ListenAndServe outbound server
-func ListenAndServe(addr string, handler OutboundHandler) error {
-	listener, err := net.Listen("tcp", addr)
+func (s *OutboundServer) init() {
+	if s.ctx == nil {
+		s.ctx, s.stop = context.WithCancel(context.Background())
+		s.listeners = make(map[net.Listener]struct{})
+		s.conns = make(map[*Connection]struct{})
+	}
+}
+
+// ListenAndServe listen on Addr and serve, always returns a non-nil error, ErrServerClosed after Shutdown or Close
+func (s *OutboundServer) ListenAndServe() error {
+	var listener net.Listener
+	var err error
+	if s.TLSConfig != nil {
+		listener, err = tls.Listen("tcp", s.Addr, s.TLSConfig)
+	} else {
+		listener, err = net.Listen("tcp", s.Addr)
+	}
 	if err != nil {
 		logger.Error(err)
 		return err
 	}
-	server.listener = listener
-	server.ctx, server.stop = context.WithCancel(context.Background())
+	return s.Serve(listener)
+}
+
+// Serve accept connections on listener and run Handler for each, always returns a non-nil error,
+// ErrServerClosed after Shutdown or Close. The listener is closed on return.
+func (s *OutboundServer) Serve(listener net.Listener) error {
+	s.mtx.Lock()
+	s.init()
+	if s.closed {
+		s.mtx.Unlock()
+		listener.Close()
+		return ErrServerClosed
+	}
+	s.listeners[listener] = struct{}{}
+	s.mtx.Unlock()
+
+	defer func() {
+		s.mtx.Lock()
+		delete(s.listeners, listener)
+		s.mtx.Unlock()
+		listener.Close()
+	}()
+
 	logger.Infof("Listenting for new ESL connections on %s\n", listener.Addr().String())
 	for {
 		c, err := listener.Accept()
 		if err != nil {
+			if s.shuttingDown() {
+				logger.Info("outbound server shutting down")
+				return ErrServerClosed
+			}
 			logger.Error(err)
-			break
+			return err
 		}
 		logger.Infof("New outbound connection from %s\n", c.RemoteAddr().String())
-		conn := newConnect(server.ctx, c, true)
+		s.serveConn(c)
+	}
+}
+
+func (s *OutboundServer) serveConn(c net.Conn) {
+	s.mtx.Lock()
+	if s.closed {
+		s.mtx.Unlock()
+		c.Close()
+		return
+	}
+	conn := newConnect(s.ctx, c, true)
+	if s.Dispatch.Workers > 0 {
+		conn.filter.dispatch = newDispatcher(s.Dispatch)
+	}
+	s.conns[conn] = struct{}{}
+	s.wg.Add(1)
+	s.mtx.Unlock()
 
-		go conn.receiveLoop()
-		go conn.eventLoop()
+	go conn.receiveLoop()
+	go conn.eventLoop()
 
-		handlerCtx, cancel := context.WithCancel(conn.runningContext)
-		go conn.dummyLoop(cancel)
-		go conn.outboundHandle(handlerCtx, handler)
+	handlerCtx, cancel := context.WithCancel(conn.runningContext)
+	go conn.dummyLoop(cancel)
+	handled := make(chan struct{})
+	go func() {
+		defer close(handled)
+		conn.outboundHandle(handlerCtx, s.Handler)
+	}()
+
+	// the call is in flight until its handler returned, the socket may stay open after that (async, linger)
+	go func() {
+		<-handled
+		s.wg.Done()
+		if s.shuttingDown() {
+			conn.Close()
+		}
+		<-conn.runningContext.Done()
+		if conn.filter.dispatch != nil {
+			conn.filter.dispatch.close()
+		}
+		s.mtx.Lock()
+		delete(s.conns, conn)
+		s.mtx.Unlock()
+	}()
+}
+
+func (s *OutboundServer) shuttingDown() bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	return s.closed
+}
+
+// closeListeners stop accepting new connections
+func (s *OutboundServer) closeListeners() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.init()
+	s.closed = true
+	for l := range s.listeners {
+		l.Close()
+	}
+}
+
+// Conns connections currently served
+func (s *OutboundServer) Conns() []*Connection {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	conns := make([]*Connection, 0, len(s.conns))
+	for c := range s.conns {
+		conns = append(conns, c)
+	}
+	return conns
+}
+
+// Shutdown stop accepting and wait until every call is done, i.e. its handler returned. A connection
+// is closed once its handler returned, even when FreeSWITCH keeps the socket open. When ctx is done
+// first, the remaining connections are closed and ctx.Err() is returned.
+func (s *OutboundServer) Shutdown(ctx context.Context) error {
+	s.closeListeners()
+
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		s.stop()
+		// handlers returned before the shutdown, their sockets are still open
+		for _, c := range s.Conns() {
+			c.Close()
+		}
+		return nil
+	case <-ctx.Done():
+		logger.Warnf("outbound server shutdown: force close %d connections\n", len(s.Conns()))
+		s.stop()
+		for _, c := range s.Conns() {
+			c.Close()
+		}
+		return ctx.Err()
+	}
+}
+
+// Close stop accepting and close all connections immediately
+func (s *OutboundServer) Close() error {
+	s.closeListeners()
+	s.stop()
+	for _, c := range s.Conns() {
+		c.Close()
 	}
-	logger.Info("outbound server shutting down")
-	server.stop()
 	return nil
 }
 
-// Shutdown shutdown the outbound server
+var (
+	serverMtx sync.Mutex
+	server    *OutboundServer
+)
+
+// ListenAndServe outbound server
+func ListenAndServe(addr string, handler OutboundHandler) error {
+	return listenAndServe(&OutboundServer{Addr: addr, Handler: handler})
+}
+
+// ListenAndServeTLS outbound server accepting TLS connections. For mutual TLS set config.ClientAuth
+// to tls.RequireAndVerifyClientCert and config.ClientCAs to the CA of the FreeSWITCH side certificates.
+func ListenAndServeTLS(addr string, config *tls.Config, handler OutboundHandler) error {
+	return listenAndServe(&OutboundServer{Addr: addr, Handler: handler, TLSConfig: config})
+}
+
+// listenAndServe run s as the package level server stopped by Shutdown
+func listenAndServe(s *OutboundServer) error {
+	serverMtx.Lock()
+	server = s
+	serverMtx.Unlock()
+
+	err := s.ListenAndServe()
+	if err == ErrServerClosed {
+		return nil
+	}
+	return err
+}
+
+// Shutdown shutdown the outbound server started by ListenAndServe, closing its connections
 func Shutdown() {
-	server.listener.Close()
-	<-server.ctx.Done()
+	serverMtx.Lock()
+	s := server
+	serverMtx.Unlock()
+
+	if s != nil {
+		s.Close()
+	}
 }
 
 func (c *Connection) outboundHandle(ctx context.Context, handler OutboundHandler) {
@@ -58,19 +256,31 @@ func (c *Connection) outboundHandle(ctx context.Context, handler OutboundHandler
 }
 
 func (c *Connection) dummyLoop(cancel context.CancelFunc) {
+	// close deletes the channels from the map
+	c.responseChnMtx.RLock()
+	disconnectChn := c.responseChns[TypeDisconnect]
+	authChn := c.responseChns[TypeAuthRequest]
+	c.responseChnMtx.RUnlock()
+
 	for {
 		select {
-		case e := <-c.responseChns[TypeDisconnect]:
+		case e, ok := <-disconnectChn:
+			if !ok {
+				return
+			}
 			disposition := e.GetHeader("Content-Disposition")
 			if disposition == "linger" {
-				logger.Info("received linger disconnect...")
+				c.logger().Info("received linger disconnect...")
 				cancel()
 				continue
 			}
-			logger.Warnf("Disconnect outbound connection %s\n", c.conn.RemoteAddr().String())
+			c.logger().Warnf("Disconnect outbound connection %s\n", c.remoteAddr.String())
 			c.Close()
-		case <-c.responseChns[TypeAuthRequest]:
-			logger.Infof("Ignoring auth request on outbound connectiong %s\n", c.conn.RemoteAddr().String())
+		case _, ok := <-authChn:
+			if !ok {
+				return
+			}
+			c.logger().Infof("Ignoring auth request on outbound connectiong %s\n", c.remoteAddr.String())
 		case <-c.runningContext.Done():
 			return
 		}
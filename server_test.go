@@ -0,0 +1,144 @@
+package esl
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+// startOutboundServer serve handler on a random port, the returned channel gets the Serve error
+func startOutboundServer(t *testing.T, handler OutboundHandler) (*OutboundServer, string, <-chan error) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewOutboundServer(l.Addr().String(), handler)
+	served := make(chan error, 1)
+	go func() {
+		served <- s.Serve(l)
+	}()
+	t.Cleanup(func() {
+		s.Close()
+	})
+	return s, l.Addr().String(), served
+}
+
+func TestOutboundServer_Shutdown(t *testing.T) {
+	fs := newTestServer(t)
+	handler := func(ctx context.Context, conn *Connection) {
+		conn.SendCommand(ctx, command.Connect{})
+		<-ctx.Done()
+	}
+	graceful, addr1, served1 := startOutboundServer(t, handler)
+	forced, addr2, served2 := startOutboundServer(t, handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	call1, err := fs.DialOutbound(addr1, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	call2, err := fs.DialOutbound(addr2, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, call := range []*esltest.Conn{call1, call2} {
+		if _, err := call.WaitCommand(ctx, "connect"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(graceful.Conns()) != 1 || len(forced.Conns()) != 1 {
+		t.Fatalf("Conns() = %d, %d", len(graceful.Conns()), len(forced.Conns()))
+	}
+
+	// the call outlives the deadline and is closed
+	short, cancelShort := context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancelShort()
+	if err := forced.Shutdown(short); err != context.DeadlineExceeded {
+		t.Errorf("forced Shutdown() = %v", err)
+	}
+	select {
+	case <-call2.Done():
+	case <-ctx.Done():
+		t.Fatal("call not closed by forced shutdown")
+	}
+	if err := <-served2; err != ErrServerClosed {
+		t.Errorf("Serve() = %v", err)
+	}
+
+	// the other server keeps serving its call until the caller hangs up
+	shutdown := make(chan error, 1)
+	go func() {
+		shutdown <- graceful.Shutdown(ctx)
+	}()
+	select {
+	case err := <-shutdown:
+		t.Fatalf("Shutdown() returned %v with a call in flight", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+	if _, err := net.Dial("tcp", addr1); err == nil {
+		t.Error("server still accepting after Shutdown")
+	}
+	call1.Disconnect(false)
+	if err := <-shutdown; err != nil {
+		t.Errorf("graceful Shutdown() = %v", err)
+	}
+	if err := <-served1; err != ErrServerClosed {
+		t.Errorf("Serve() = %v", err)
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := graceful.Serve(l); err != ErrServerClosed {
+		t.Errorf("Serve() after Shutdown = %v", err)
+	}
+}
+
+func TestOutboundServer_ShutdownHandlerReturned(t *testing.T) {
+	fs := newTestServer(t)
+	handler := func(ctx context.Context, conn *Connection) {
+		conn.SendCommand(ctx, command.Connect{})
+	}
+	s, addr, served := startOutboundServer(t, handler)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	call, err := fs.DialOutbound(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := call.WaitCommand(ctx, "connect"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the handler returned, FreeSWITCH keeps the socket open: nothing is in flight
+	if err := s.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	select {
+	case <-call.Done():
+	case <-ctx.Done():
+		t.Fatal("connection left open by Shutdown")
+	}
+	if err := <-served; err != ErrServerClosed {
+		t.Errorf("Serve() = %v", err)
+	}
+}
+
+func TestOutboundServer_ShutdownNotStarted(t *testing.T) {
+	done := make(chan struct{})
+	go func() {
+		NewOutboundServer(":0", nil).Shutdown(context.Background())
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Shutdown of a server never started blocks")
+	}
+}
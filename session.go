@@ -0,0 +1,189 @@
+package esl
+
+import (
+	"context"
+	"fmt"
+	"net/textproto"
+	"strings"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/command/call"
+)
+
+// ChannelData channel of an outbound connection, parsed from the CHANNEL_DATA reply of `connect`
+type ChannelData struct {
+	Channel
+	// ChannelName e.g. sofia/internal/1000@10.0.0.1
+	ChannelName string
+	// Response raw reply of `connect`
+	Response *RawResponse
+}
+
+func newChannelData(resp *RawResponse) ChannelData {
+	e := &Event{Headers: make(textproto.MIMEHeader)}
+	for k, v := range resp.Headers {
+		e.Headers[k] = v
+	}
+	data := ChannelData{
+		Channel: Channel{
+			UUID:      resp.ChannelUUID(),
+			Variables: make(map[string]string),
+		},
+		ChannelName: resp.GetHeader("Channel-Name"),
+		Response:    resp,
+	}
+	data.update(e)
+	return data
+}
+
+// SessionOptions commands sent by NewOutboundSession after `connect`
+type SessionOptions struct {
+	// EventFormat format of myevents, the connection format when empty
+	EventFormat string
+	// MyEvents subscribe the events of this call
+	MyEvents bool
+	// DivertEvents divert events of embedded scripts to the socket
+	DivertEvents bool
+	// Linger keep the socket open after hangup, for LingerSeconds or until closed when zero
+	Linger        bool
+	LingerSeconds int
+}
+
+// OutboundSession outbound connection of one call, connected and with its channel data.
+// The media helpers of Call are promoted, with MyEvents they need no other subscription.
+type OutboundSession struct {
+	*Connection
+	*Call
+	ChannelData ChannelData
+}
+
+// NewOutboundSession send `connect` on an outbound connection, then the commands selected by opts
+func NewOutboundSession(ctx context.Context, conn *Connection, opts SessionOptions) (*OutboundSession, error) {
+	resp, err := conn.SendCommand(ctx, command.Connect{})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.IsOk() {
+		return nil, replyError(resp)
+	}
+	s := &OutboundSession{
+		Connection:  conn,
+		ChannelData: newChannelData(resp),
+	}
+	s.Call = conn.Call(s.ChannelData.UUID)
+
+	if len(opts.EventFormat) > 0 {
+		if err := conn.SetEventFormat(opts.EventFormat); err != nil {
+			return nil, err
+		}
+	}
+	cmds := make([]command.Command, 0, 3)
+	if opts.MyEvents {
+		cmds = append(cmds, command.MyEvents{Format: conn.EventFormat()})
+	}
+	if opts.DivertEvents {
+		cmds = append(cmds, command.DivertEvents{Enabled: true})
+	}
+	if opts.Linger {
+		cmds = append(cmds, command.Linger{Enabled: true, Duration: opts.LingerSeconds})
+	}
+	for _, cmd := range cmds {
+		if err := s.send(ctx, cmd); err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// SessionHandler adapt a session handler to an OutboundHandler, the connection is closed when the session
+// cannot be set up
+func SessionHandler(opts SessionOptions, handler func(ctx context.Context, s *OutboundSession)) OutboundHandler {
+	return func(ctx context.Context, conn *Connection) {
+		s, err := NewOutboundSession(ctx, conn, opts)
+		if err != nil {
+			logger.Errorf("outbound session %s: %s\n", conn.RemoteAddr(), err.Error())
+			conn.Close()
+			return
+		}
+		handler(ctx, s)
+	}
+}
+
+func replyError(resp *RawResponse) error {
+	return fmt.Errorf("%s: %w", resp.GetReply(), ErrCommandFailed)
+}
+
+// send send command and turn -ERR replies into errors
+func (s *OutboundSession) send(ctx context.Context, cmd command.Command) error {
+	resp, err := s.SendCommand(ctx, cmd)
+	if err != nil {
+		return err
+	}
+	if !resp.IsOk() {
+		return replyError(resp)
+	}
+	return nil
+}
+
+// UUID uuid of the call
+func (s *OutboundSession) UUID() string {
+	return s.ChannelData.UUID
+}
+
+// Execute execute a dialplan application on the call without waiting for it to complete
+func (s *OutboundSession) Execute(ctx context.Context, app, args string) error {
+	return s.send(ctx, &call.Execute{
+		UUID:    s.UUID(),
+		AppName: app,
+		AppArgs: args,
+	})
+}
+
+// Answer answer the call
+func (s *OutboundSession) Answer(ctx context.Context) error {
+	return s.Execute(ctx, "answer", "")
+}
+
+// Hangup hang up the call with cause, NORMAL_CLEARING when empty
+func (s *OutboundSession) Hangup(ctx context.Context, cause string) error {
+	if len(cause) == 0 {
+		cause = "NORMAL_CLEARING"
+	}
+	return s.send(ctx, call.Hangup{UUID: s.UUID(), Cause: cause})
+}
+
+// Set set a channel variable
+func (s *OutboundSession) Set(ctx context.Context, key, value string) error {
+	return s.send(ctx, call.Set{UUID: s.UUID(), Key: key, Value: value})
+}
+
+// Export export a channel variable to the bridged leg
+func (s *OutboundSession) Export(ctx context.Context, key, value string) error {
+	return s.send(ctx, call.Export{UUID: s.UUID(), Key: key, Value: value})
+}
+
+// GetVariable read a channel variable with `api uuid_getvar`, empty when not set
+func (s *OutboundSession) GetVariable(ctx context.Context, name string) (string, error) {
+	resp, err := s.SendCommand(ctx, command.API{Command: "uuid_getvar", Arguments: s.UUID() + " " + name})
+	if err != nil {
+		return "", err
+	}
+	value := strings.TrimRight(string(resp.Body), "\r\n")
+	if strings.HasPrefix(value, "-ERR") {
+		return "", fmt.Errorf("%s: %w", value, ErrCommandFailed)
+	}
+	if value == "_undef_" {
+		return "", nil
+	}
+	return value, nil
+}
+
+// OnEvent handle events named name of this call only
+func (s *OutboundSession) OnEvent(name string, fn EventHandler) *Subscription {
+	uuid := s.UUID()
+	return s.FilterEvent(name, func(e *Event) {
+		if e.ChannelUUID() == uuid {
+			fn(e)
+		}
+	})
+}
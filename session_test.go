@@ -0,0 +1,124 @@
+package esl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestOutboundSession(t *testing.T) {
+	fs := newTestServer(t)
+	fs.HandleAPI("uuid_getvar", func(args string) string {
+		if args == "call-1 sip_call_id" {
+			return "abc@host"
+		}
+		return "_undef_"
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	sessions := make(chan *OutboundSession, 1)
+	answered := make(chan *Event, 2)
+	_, addr, _ := startOutboundServer(t, SessionHandler(SessionOptions{
+		EventFormat:  EventFormatJSON,
+		MyEvents:     true,
+		DivertEvents: true,
+		Linger:       true,
+	}, func(ctx context.Context, s *OutboundSession) {
+		s.OnEvent(EventChannelAnswer, func(e *Event) {
+			answered <- e
+		})
+		sessions <- s
+		<-ctx.Done()
+	}))
+
+	call, err := fs.DialOutbound(addr, esltest.NewEvent("CHANNEL_DATA",
+		"Unique-ID", "call-1",
+		"Channel-Name", "sofia/internal/1000@10.0.0.1",
+		"Channel-State", "CS_EXECUTE",
+		"Call-Direction", "inbound",
+		"Caller-Caller-ID-Number", "1000",
+		"Caller-Destination-Number", "9999",
+		"variable_sip_call_id", "abc@host",
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer call.Close()
+
+	var s *OutboundSession
+	select {
+	case s = <-sessions:
+	case <-ctx.Done():
+		t.Fatal("session not started")
+	}
+	data := s.ChannelData
+	if s.UUID() != "call-1" || data.ChannelName != "sofia/internal/1000@10.0.0.1" || data.State != ChannelStateExecute ||
+		data.Direction != "inbound" || data.CallerIDNumber != "1000" || data.DestinationNumber != "9999" {
+		t.Errorf("ChannelData = %#v", data)
+	}
+	if data.GetVariable("sip_call_id") != "abc@host" {
+		t.Errorf("sip_call_id = %q", data.GetVariable("sip_call_id"))
+	}
+	for _, line := range []string{"connect", "myevents json", "divert_events on", "linger"} {
+		if _, err := call.WaitCommand(ctx, line); err != nil {
+			t.Fatalf("%s: %s", line, err)
+		}
+	}
+
+	if err := s.Answer(ctx); err != nil {
+		t.Fatal(err)
+	}
+	cmd, err := call.WaitCommand(ctx, "sendmsg call-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmd.Headers.Get("Execute-App-Name") != "answer" {
+		t.Errorf("sendmsg headers = %v", cmd.Headers)
+	}
+	if v, err := s.GetVariable(ctx, "sip_call_id"); err != nil || v != "abc@host" {
+		t.Errorf("GetVariable(sip_call_id) = %q, %v", v, err)
+	}
+	if v, err := s.GetVariable(ctx, "missing"); err != nil || v != "" {
+		t.Errorf("GetVariable(missing) = %q, %v", v, err)
+	}
+
+	call.SendEvent(esltest.NewEvent(EventChannelAnswer, "Unique-ID", "other-call"))
+	call.SendEvent(esltest.NewEvent(EventChannelAnswer, "Unique-ID", "call-1"))
+	select {
+	case e := <-answered:
+		if e.ChannelUUID() != "call-1" {
+			t.Errorf("OnEvent got event of %q", e.ChannelUUID())
+		}
+	case <-ctx.Done():
+		t.Fatal("CHANNEL_ANSWER not received")
+	}
+}
+
+func TestOutboundSession_ConnectFailed(t *testing.T) {
+	fs := newTestServer(t)
+	fs.Handle("connect", func(c *esltest.Conn, cmd *esltest.Command) bool {
+		c.Reply("-ERR not now")
+		return true
+	})
+	called := make(chan struct{}, 1)
+	_, addr, _ := startOutboundServer(t, SessionHandler(SessionOptions{}, func(ctx context.Context, s *OutboundSession) {
+		called <- struct{}{}
+	}))
+	call, err := fs.DialOutbound(addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-call.Done():
+	case <-time.After(3 * time.Second):
+		t.Fatal("connection not closed after failed connect")
+	}
+	select {
+	case <-called:
+		t.Error("handler called without session")
+	default:
+	}
+}
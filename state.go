@@ -0,0 +1,201 @@
+package esl
+
+import (
+	"errors"
+	"strconv"
+)
+
+// ClientState state of a client connection
+type ClientState int
+
+// client states
+const (
+	StateDisconnected ClientState = iota
+	StateConnecting
+	StateAuthenticating
+	StateConnected
+	StateReconnecting
+	StateStopped
+)
+
+func (s ClientState) String() string {
+	switch s {
+	case StateDisconnected:
+		return "disconnected"
+	case StateConnecting:
+		return "connecting"
+	case StateAuthenticating:
+		return "authenticating"
+	case StateConnected:
+		return "connected"
+	case StateReconnecting:
+		return "reconnecting"
+	case StateStopped:
+		return "stopped"
+	}
+	return "unknown"
+}
+
+// ConnectionID identifies the main connection or one of the send connections of a Client
+type ConnectionID int
+
+// MainConnection id of the main connection, send connections are numbered from 0
+const MainConnection ConnectionID = -1
+
+func (id ConnectionID) String() string {
+	if id == MainConnection {
+		return "main"
+	}
+	return "send-" + strconv.Itoa(int(id))
+}
+
+// hooks lifecycle callbacks, called from the connection loops and must not block
+type hooks struct {
+	stateChange func(from, to ClientState)
+	connect     func(id ConnectionID)
+	disconnect  func(id ConnectionID, err error)
+	authFailure func(id ConnectionID, err error)
+}
+
+// OnStateChange set callback called on every state transition of the main connection
+func (c *Client) OnStateChange(fn func(from, to ClientState)) {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	c.hooks.stateChange = fn
+}
+
+// OnConnect set callback called once a connection is authenticated and subscribed
+func (c *Client) OnConnect(fn func(id ConnectionID)) {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	c.hooks.connect = fn
+}
+
+// OnDisconnect set callback called when an established connection is lost, err tells why
+func (c *Client) OnDisconnect(fn func(id ConnectionID, err error)) {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	c.hooks.disconnect = fn
+}
+
+// OnAuthFailure set callback called when the server rejects the password
+func (c *Client) OnAuthFailure(fn func(id ConnectionID, err error)) {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	c.hooks.authFailure = fn
+}
+
+// State state of the main connection
+func (c *Client) State() ClientState {
+	return c.ConnState(MainConnection)
+}
+
+// ConnState state of the main connection or of a send connection, StateDisconnected for unknown ids
+func (c *Client) ConnState(id ConnectionID) ClientState {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	if id == MainConnection {
+		return c.state
+	}
+	if int(id) < 0 || int(id) >= len(c.sendStates) {
+		return StateDisconnected
+	}
+	return c.sendStates[id]
+}
+
+func (c *Client) setState(state ClientState) {
+	c.setConnState(MainConnection, -1, state, nil)
+}
+
+// setConnState move connection to state and run the hooks. gen is the generation of a send connection,
+// reports of a send connection replaced by a reconnect are dropped.
+func (c *Client) setConnState(id ConnectionID, gen int, state ClientState, err error) {
+	c.stateMtx.Lock()
+	var from ClientState
+	if id == MainConnection {
+		from = c.state
+		c.state = state
+	} else {
+		if int(id) >= len(c.sendStates) || c.sendGens[id] != gen || c.sendStates[id] == StateStopped {
+			c.stateMtx.Unlock()
+			return
+		}
+		from = c.sendStates[id]
+		c.sendStates[id] = state
+	}
+	h := c.hooks
+	c.stateMtx.Unlock()
+
+	if from == state {
+		return
+	}
+	c.logger().Infof("%s connection state %s -> %s\n", id, from, state)
+	if id == MainConnection && h.stateChange != nil {
+		h.stateChange(from, state)
+	}
+	switch {
+	case state == StateConnected && h.connect != nil:
+		h.connect(id)
+	case state == StateDisconnected && from == StateConnected && h.disconnect != nil:
+		h.disconnect(id, err)
+	}
+}
+
+// authFailed report rejected credentials
+func (c *Client) authFailed(id ConnectionID, err error) {
+	c.stateMtx.Lock()
+	fn := c.hooks.authFailure
+	c.stateMtx.Unlock()
+
+	c.logger().Errorf("%s connection authentication failed: %s\n", id, err.Error())
+	if fn != nil {
+		fn(id, err)
+	}
+}
+
+// newSendGeneration start tracking a new send connection at index i, a connected predecessor is reported lost
+func (c *Client) newSendGeneration(i int) {
+	c.stateMtx.Lock()
+	if len(c.sendStates) != c.sendConnCnt {
+		c.sendStates = make([]ClientState, c.sendConnCnt)
+		c.sendGens = make([]int, c.sendConnCnt)
+	}
+	from := c.sendStates[i]
+	c.sendGens[i]++
+	c.sendStates[i] = StateConnecting
+	fn := c.hooks.disconnect
+	c.stateMtx.Unlock()
+
+	if from == StateConnected && fn != nil {
+		fn(ConnectionID(i), ErrConnClosed)
+	}
+}
+
+// sendReporter state reporter of the current send connection at index i
+func (c *Client) sendReporter(i int) func(state ClientState, err error) {
+	c.stateMtx.Lock()
+	gen := c.sendGens[i]
+	c.stateMtx.Unlock()
+
+	return func(state ClientState, err error) {
+		if state == StateDisconnected && errors.Is(err, ErrInvalidPassword) {
+			c.authFailed(ConnectionID(i), err)
+		}
+		c.setConnState(ConnectionID(i), gen, state, err)
+	}
+}
+
+// stopSendStates mark all send connections stopped
+func (c *Client) stopSendStates() {
+	c.stateMtx.Lock()
+	defer c.stateMtx.Unlock()
+
+	for i := range c.sendStates {
+		c.sendStates[i] = StateStopped
+	}
+}
@@ -0,0 +1,186 @@
+package esl
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+type hookRecorder struct {
+	sync.Mutex
+	connected    map[ConnectionID]int
+	disconnected map[ConnectionID]int
+	authFailed   map[ConnectionID]error
+	notify       chan struct{}
+}
+
+func newHookRecorder(c *Client) *hookRecorder {
+	r := &hookRecorder{
+		connected:    make(map[ConnectionID]int),
+		disconnected: make(map[ConnectionID]int),
+		authFailed:   make(map[ConnectionID]error),
+		notify:       make(chan struct{}, 64),
+	}
+	c.OnConnect(func(id ConnectionID) {
+		r.Lock()
+		r.connected[id]++
+		r.Unlock()
+		r.notify <- struct{}{}
+	})
+	c.OnDisconnect(func(id ConnectionID, err error) {
+		r.Lock()
+		r.disconnected[id]++
+		r.Unlock()
+		r.notify <- struct{}{}
+	})
+	c.OnAuthFailure(func(id ConnectionID, err error) {
+		r.Lock()
+		r.authFailed[id] = err
+		r.Unlock()
+		r.notify <- struct{}{}
+	})
+	return r
+}
+
+// wait until cond holds
+func (r *hookRecorder) wait(ctx context.Context, cond func() bool) bool {
+	for {
+		r.Lock()
+		ok := cond()
+		r.Unlock()
+		if ok {
+			return true
+		}
+		select {
+		case <-r.notify:
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+func TestClient_Hooks(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Reconnect = ReconnectPolicy{InitialDelay: 10 * time.Millisecond}
+	r := newHookRecorder(client)
+	if client.State() != StateDisconnected {
+		t.Errorf("State() before Start = %s", client.State())
+	}
+	if err := client.Start("plain", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ids := []ConnectionID{MainConnection, 0, 1}
+	if !r.wait(ctx, func() bool {
+		for _, id := range ids {
+			if r.connected[id] != 1 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("connected = %v", r.connected)
+	}
+	for _, id := range ids {
+		if client.ConnState(id) != StateConnected {
+			t.Errorf("ConnState(%s) = %s", id, client.ConnState(id))
+		}
+	}
+	if client.ConnState(5) != StateDisconnected {
+		t.Errorf("ConnState(5) = %s", client.ConnState(5))
+	}
+
+	for _, c := range server.Conns() {
+		c.Disconnect(false)
+	}
+	if !r.wait(ctx, func() bool {
+		for _, id := range ids {
+			if r.disconnected[id] != 1 || r.connected[id] != 2 {
+				return false
+			}
+		}
+		return true
+	}) {
+		t.Fatalf("connected = %v, disconnected = %v", r.connected, r.disconnected)
+	}
+
+	client.Stop()
+	for _, id := range ids {
+		if client.ConnState(id) != StateStopped {
+			t.Errorf("ConnState(%s) after Stop = %s", id, client.ConnState(id))
+		}
+	}
+}
+
+func TestClient_HooksReadError(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), esltest.DefaultPassword, 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.Reconnect = ReconnectPolicy{InitialDelay: 10 * time.Millisecond}
+	r := newHookRecorder(client)
+	states := make(chan ClientState, 16)
+	client.OnStateChange(func(from, to ClientState) {
+		states <- to
+	})
+	if err := client.Start("plain", "BACKGROUND_JOB"); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(client.Stop)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	conn, err := server.WaitConn(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the receive loop dies on a malformed frame, not on EOF
+	conn.Write("Content-Type: api/response\nContent-Length: x\n\n")
+	if !r.wait(ctx, func() bool {
+		return r.disconnected[MainConnection] == 1 && r.connected[MainConnection] == 2
+	}) {
+		t.Fatalf("connected = %v, disconnected = %v", r.connected, r.disconnected)
+	}
+	var reconnecting bool
+	for !reconnecting {
+		select {
+		case s := <-states:
+			reconnecting = s == StateReconnecting
+		case <-ctx.Done():
+			t.Fatal("State() never left connected")
+		}
+	}
+	if client.State() != StateConnected {
+		t.Errorf("State() after reconnect = %s", client.State())
+	}
+}
+
+func TestClient_OnAuthFailure(t *testing.T) {
+	server := newTestServer(t)
+	client, err := NewClient(server.Host(), server.Port(), "wrong", 3, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := newHookRecorder(client)
+	if err := client.Start("plain", "BACKGROUND_JOB"); !errors.Is(err, ErrInvalidPassword) {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if !errors.Is(r.authFailed[MainConnection], ErrInvalidPassword) {
+		t.Errorf("authFailed = %v", r.authFailed)
+	}
+	if client.State() != StateDisconnected || len(r.connected) != 0 {
+		t.Errorf("State() = %s, connected = %v", client.State(), r.connected)
+	}
+}
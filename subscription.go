@@ -0,0 +1,212 @@
+package esl
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/zhifeichen/esl/v2/command"
+)
+
+// subscriptions server side state set by successful commands, replayed after reconnect
+type subscriptions struct {
+	sync.Mutex
+	format string
+	events []string
+	// custom CUSTOM is subscribed, with subclasses
+	custom     bool
+	subclasses []string
+	myEvents   *command.MyEvents
+	divert     *command.DivertEvents
+	filters    []command.Filter
+	log        *command.Log
+}
+
+func newSubscriptions() *subscriptions {
+	return &subscriptions{}
+}
+
+// record update state with a command accepted by the server, other commands are ignored
+func (s *subscriptions) record(cmd command.Command) {
+	switch p := cmd.(type) {
+	case *command.Event:
+		cmd = *p
+	case *command.Filter:
+		cmd = *p
+	}
+
+	s.Lock()
+	defer s.Unlock()
+
+	switch cmd := cmd.(type) {
+	case command.Event:
+		if cmd.Ignore {
+			s.removeEvents(cmd.Listen)
+			return
+		}
+		s.format = cmd.Format
+		s.addEvents(cmd.Listen)
+	case command.MyEvents:
+		s.myEvents = &cmd
+	case command.DisableEvents:
+		s.format, s.events, s.myEvents = "", nil, nil
+		s.custom, s.subclasses = false, nil
+	case command.DivertEvents:
+		s.divert = &cmd
+	case command.Filter:
+		s.recordFilter(cmd)
+	case command.Log:
+		if !cmd.Enabled {
+			s.log = nil
+			return
+		}
+		s.log = &cmd
+	}
+}
+
+// splitEvents event names and CUSTOM subclasses of an event or nixevent command. FreeSWITCH takes
+// every word after CUSTOM as a subclass.
+func splitEvents(listen []string) (names []string, custom bool, subclasses []string) {
+	for _, l := range listen {
+		for _, word := range strings.Fields(l) {
+			switch {
+			case custom:
+				subclasses = append(subclasses, word)
+			case word == "CUSTOM":
+				custom = true
+			default:
+				names = append(names, word)
+			}
+		}
+	}
+	return names, custom, subclasses
+}
+
+func (s *subscriptions) addEvents(listen []string) {
+	names, custom, subclasses := splitEvents(listen)
+	s.events = appendMissing(s.events, names)
+	if custom {
+		s.custom = true
+		s.subclasses = appendMissing(s.subclasses, subclasses)
+	}
+}
+
+func (s *subscriptions) removeEvents(listen []string) {
+	names, custom, subclasses := splitEvents(listen)
+	s.events = removeAll(s.events, names)
+	if !custom {
+		return
+	}
+	// nixevent CUSTOM without subclass drops them all
+	if len(subclasses) == 0 {
+		s.custom, s.subclasses = false, nil
+		return
+	}
+	s.subclasses = removeAll(s.subclasses, subclasses)
+	s.custom = len(s.subclasses) > 0
+}
+
+func appendMissing(list, values []string) []string {
+	for _, v := range values {
+		if !StringInSlice(v, list) {
+			list = append(list, v)
+		}
+	}
+	return list
+}
+
+func removeAll(list, values []string) []string {
+	kept := list[:0]
+	for _, v := range list {
+		if !StringInSlice(v, values) {
+			kept = append(kept, v)
+		}
+	}
+	return kept
+}
+
+func (s *subscriptions) recordFilter(f command.Filter) {
+	if !f.Delete {
+		for _, exist := range s.filters {
+			if exist == f {
+				return
+			}
+		}
+		s.filters = append(s.filters, f)
+		return
+	}
+	filters := s.filters[:0]
+	for _, exist := range s.filters {
+		// without value all filters of the header are deleted
+		if exist.EventHeader == f.EventHeader && (len(f.FilterValue) == 0 || exist.FilterValue == f.FilterValue) {
+			continue
+		}
+		filters = append(filters, exist)
+	}
+	s.filters = filters
+}
+
+// filter server side filter of header and value, header names are case insensitive
+func (s *subscriptions) filter(header, value string) (command.Filter, bool) {
+	s.Lock()
+	defer s.Unlock()
+
+	for _, f := range s.filters {
+		if strings.EqualFold(f.EventHeader, header) && f.FilterValue == value {
+			return f, true
+		}
+	}
+	return command.Filter{}, false
+}
+
+// empty nothing to replay
+func (s *subscriptions) empty() bool {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.events) == 0 && !s.custom && s.myEvents == nil && s.divert == nil && len(s.filters) == 0 && s.log == nil
+}
+
+// commands commands restoring the recorded state
+func (s *subscriptions) commands() []command.Command {
+	s.Lock()
+	defer s.Unlock()
+
+	cmds := make([]command.Command, 0, len(s.filters)+4)
+	if len(s.events) > 0 || s.custom {
+		events := make([]string, len(s.events), len(s.events)+len(s.subclasses)+1)
+		copy(events, s.events)
+		// subclasses last, the words after CUSTOM are not event names
+		if s.custom {
+			events = append(append(events, "CUSTOM"), s.subclasses...)
+		}
+		cmds = append(cmds, command.Event{Format: s.format, Listen: events})
+	}
+	if s.myEvents != nil {
+		cmds = append(cmds, *s.myEvents)
+	}
+	if s.divert != nil {
+		cmds = append(cmds, *s.divert)
+	}
+	for _, f := range s.filters {
+		cmds = append(cmds, f)
+	}
+	if s.log != nil {
+		cmds = append(cmds, *s.log)
+	}
+	return cmds
+}
+
+// replaySubscriptions resend events, filters, myevents, divert_events and log level recorded on this connection
+func (c *Connection) replaySubscriptions(ctx context.Context) error {
+	for _, cmd := range c.subscriptions.commands() {
+		response, err := c.SendCommand(ctx, cmd)
+		if err != nil {
+			return err
+		}
+		if !response.IsOk() {
+			c.logger().Warnf("replay %s: %s\n", cmd.BuildMessage(), response.GetReply())
+		}
+	}
+	return nil
+}
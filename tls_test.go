@@ -0,0 +1,96 @@
+package esl
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/zhifeichen/esl/v2/command"
+	"github.com/zhifeichen/esl/v2/esltest"
+)
+
+func TestDial_TLS(t *testing.T) {
+	certs, err := esltest.NewCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server, err := esltest.NewTLSServer(esltest.DefaultPassword, certs.ServerConfig(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(server.Close)
+	server.HandleAPI("status", func(args string) string {
+		return "UP\n"
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client, err := Dial(ctx, server.Addr(), WithPassword(esltest.DefaultPassword), WithTLS(certs.ClientConfig(true)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Stop()
+	resp, err := client.SendCommand(ctx, command.API{Command: "status"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(resp.Body) != "UP\n" {
+		t.Errorf("status = %q", resp.Body)
+	}
+
+	// the server requires a client certificate
+	short, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	if _, err := Dial(short, server.Addr(), WithPassword(esltest.DefaultPassword), WithTLS(certs.ClientConfig(false))); err == nil {
+		t.Error("Dial() without client certificate succeeded")
+	}
+}
+
+func TestListenAndServeTLS(t *testing.T) {
+	certs, err := esltest.NewCertificates()
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := newTestServer(t)
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	l.Close()
+
+	results := make(chan *RawResponse, 1)
+	go ListenAndServeTLS(addr, certs.ServerConfig(true), func(ctx context.Context, conn *Connection) {
+		resp, err := conn.SendCommand(ctx, command.Connect{})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		results <- resp
+	})
+
+	var fs *esltest.Conn
+	for i := 0; i < 50; i++ {
+		fs, err = server.DialOutboundTLS(addr, esltest.NewEvent("CHANNEL_DATA", "Unique-ID", "tls-call"),
+			certs.ClientConfig(true))
+		if err == nil {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Shutdown()
+	defer fs.Close()
+
+	select {
+	case resp := <-results:
+		if resp.ChannelUUID() != "tls-call" {
+			t.Errorf("channel data Unique-ID = %q", resp.ChannelUUID())
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("outbound handler timeout")
+	}
+}
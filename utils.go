@@ -6,7 +6,12 @@
 
 package esl
 
-import "github.com/zhifeichen/log"
+import (
+	"crypto/rand"
+	"fmt"
+
+	"github.com/zhifeichen/log"
+)
 
 // StringInSlice - Will check if string in list. This is equivalent to python if x in []
 // @TODO - What the fuck Nevio...
@@ -19,6 +24,15 @@ func StringInSlice(str string, list []string) bool {
 	return false
 }
 
+// newUUID generate random uuid, used to preset Job-UUID and Event-UUID
+func newUUID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
+
 func init() {
 	logger.Discard()
 }
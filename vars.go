@@ -15,7 +15,7 @@ var (
 	ReadBufferSize = 1024 << 6
 
 	// AvailableMessageTypes Freeswitch events that we can handle (have logic for it)
-	AvailableMessageTypes = []string{"auth/request", "text/disconnect-notice", "text/event-json", "text/event-plain", "api/response", "command/reply"}
+	AvailableMessageTypes = []string{"auth/request", "text/disconnect-notice", "text/event-json", "text/event-plain", "text/event-xml", "api/response", "command/reply"}
 
 	// logger
 	logger = log.New(log.NewOptions())